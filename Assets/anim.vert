@@ -1,6 +1,6 @@
 #version 330
 struct Material {
-    float animStr;
+    float animStr; // @range(0,1) @default(0.1) @tooltip("Vertex displacement along the normal")
     sampler2D noise;
 };
 
@@ -0,0 +1,62 @@
+#version 330
+struct Material {
+    vec3 color; // @color @default(0.8, 0.8, 0.8)
+    float specPower; // @range(1,256) @default(32) @tooltip("Sharpness of the specular highlight")
+};
+
+uniform Material material;
+
+// Universal uniforms
+uniform mat4 modelMatrix;
+uniform mat4 MVP;
+
+// Per frame properties, shared by all programs through one uniform buffer
+layout(std140) uniform Globals {
+    float time;
+    float deltaTime;
+    int frameIndex;
+    vec2 resolution;
+    vec2 mouse;
+    vec3 lightDir;
+    vec3 lightColor;
+    vec3 cameraWorldPos;
+    float cameraNear;
+    float cameraFar;
+};
+
+#pragma stage vertex
+in vec3 vert;
+in vec2 vertTexCoord;
+in vec3 normal;
+out vec3 fragNormal;
+out vec3 fragWorldPos;
+
+void main() {
+    fragNormal = normal;
+    fragWorldPos = (modelMatrix * vec4(vert,1)).xyz;
+	gl_Position = MVP * vec4(vert, 1);
+}
+
+#pragma stage fragment
+in vec3 fragNormal;
+in vec3 fragWorldPos;
+out vec4 outputColor;
+
+void main() {
+    // Calculate normal in world coordinates
+    mat3 worldMatrix = transpose(inverse(mat3(modelMatrix)));
+    vec3 normal = normalize(worldMatrix * fragNormal);
+    vec3 lightDirection = normalize(lightDir);
+
+    // Calculate diffuse light
+    vec3 indirectDiffuse = vec3(0.2,0.2,0.2);
+    vec3 directDiffuse = lightColor * max(dot(normal, lightDirection), 0.0);
+    vec3 diffuse = material.color * (indirectDiffuse + directDiffuse);
+
+    // Calculate specular highlight
+    vec3 viewDir = normalize(cameraWorldPos - fragWorldPos);
+    vec3 halfDir = normalize(lightDirection + viewDir);
+    float specular = pow(max(dot(halfDir, normal), 0.0), material.specPower);
+
+    outputColor = vec4(diffuse + lightColor * specular, 1);
+}
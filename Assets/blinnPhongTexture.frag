@@ -1,7 +1,8 @@
 #version 330
 struct Material {
-    float specPower;
+    float specPower; // @range(1,256) @default(50) @tooltip("Sharpness of the specular highlight")
     sampler2D tex;
+    vec2 tiling; // @default(1) @tooltip("How often the texture repeats across the UVs")
 }; 
   
 uniform Material material;
@@ -21,7 +22,7 @@ void main() {
     vec3 normal = normalize(worldMatrix * fragNormal);
 
     // Sample texture for color
-    vec4 color = texture(material.tex, fragTexCoord);
+    vec4 color = texture(material.tex, fragTexCoord * material.tiling);
 
     // Calculate diffuse light
     vec4 indirectDiffuse = vec4(0.2,0.2,0.2,1);
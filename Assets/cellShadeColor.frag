@@ -1,7 +1,7 @@
 #version 330
 struct Material {
-    vec3 color;
-    float specPower;
+    vec3 color; // @color @default(0.9, 0.4, 0.3)
+    float specPower; // @range(1,256) @default(32) @tooltip("Sharpness of the specular highlight")
     sampler2D cellRampDiffuse;
     sampler2D cellRampSpecular;
 }; 
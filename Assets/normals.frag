@@ -0,0 +1,12 @@
+#version 330
+struct Material {
+    float length; // @range(0,0.5) @default(0.1) @tooltip("Length of the normal lines")
+    vec3 color; // @color @default(1, 1, 0)
+};
+
+uniform Material material;
+
+out vec4 outputColor;
+void main() {
+    outputColor = vec4(material.color, 1);
+}
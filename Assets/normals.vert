@@ -0,0 +1,14 @@
+#version 330
+uniform mat4 modelMatrix;
+
+in vec3 vert;
+in vec2 vertTexCoord;
+in vec3 normal;
+out vec3 geomWorldPos;
+out vec3 geomWorldNormal;
+
+void main() {
+    geomWorldPos = (modelMatrix * vec4(vert, 1)).xyz;
+    geomWorldNormal = normalize(transpose(inverse(mat3(modelMatrix))) * normal);
+	gl_Position = vec4(geomWorldPos, 1);
+}
@@ -0,0 +1,14 @@
+#version 330
+// Inverted hull outline: only the back faces of the pushed out mesh are drawn
+#pragma cull front
+
+struct Material {
+    float width; // @range(0,0.2) @default(0.03) @tooltip("How far the hull is pushed out along the normals")
+    vec3 color; // @color @default(0.05, 0.05, 0.05)
+};
+uniform Material material;
+
+out vec4 outputColor;
+void main() {
+    outputColor = vec4(material.color, 1);
+}
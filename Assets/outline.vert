@@ -0,0 +1,15 @@
+#version 330
+uniform mat4 MVP;
+
+struct Material {
+    float width; // @range(0,0.2) @default(0.03) @tooltip("How far the hull is pushed out along the normals")
+    vec3 color; // @color @default(0.05, 0.05, 0.05)
+};
+uniform Material material;
+
+in vec3 vert;
+in vec3 normal;
+
+void main() {
+	gl_Position = MVP * vec4(vert + normalize(normal) * material.width, 1);
+}
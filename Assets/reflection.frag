@@ -0,0 +1,28 @@
+#version 330
+struct Material {
+    samplerCube environment; // @tooltip("The cubemap reflected by the surface")
+    vec3 tint; // @color @default(1)
+    float fresnel; // @range(0,1) @default(0.3) @tooltip("Reflectivity when looking straight at the surface")
+};
+
+uniform Material material;
+
+in vec3 fragNormal;
+in vec3 fragWorldPos;
+
+uniform mat4 modelMatrix;
+uniform vec3 cameraWorldPos;
+
+out vec4 outputColor;
+void main() {
+    mat3 worldMatrix = transpose(inverse(mat3(modelMatrix)));
+    vec3 normal = normalize(worldMatrix * fragNormal);
+    vec3 viewDir = normalize(fragWorldPos - cameraWorldPos);
+
+    // Surfaces reflect more at grazing angles
+    float facing = 1 - max(dot(-viewDir, normal), 0);
+    float reflectivity = mix(material.fresnel, 1, pow(facing, 5));
+
+    vec3 reflected = texture(material.environment, reflect(viewDir, normal)).rgb;
+    outputColor = vec4(reflected * material.tint * reflectivity, 1);
+}
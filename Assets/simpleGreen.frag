@@ -1,4 +1,6 @@
 #version 330
+#pragma multi_compile SPECULAR RIM
+
 in vec2 fragTexCoord;
 in vec3 fragNormal;
 in vec3 fragVert;
@@ -21,14 +23,19 @@ void main() {
     vec3 directDiffuse = lightColor * dot(normal, normalize(lightDir.xyz));
     vec4 diffuse = indirectDiffuse + vec4(directDiffuse,1);
 
-    float specPower = 50;
+    outputColor = color * diffuse;
     vec3 viewDir = normalize(fragWorldPos - cameraWorldPos);
+
+#ifdef SPECULAR
+    float specPower = 50;
     vec3 halfDir = normalize(lightDir.xyz + viewDir);
     float specAngle = max(dot(halfDir, normal), 0.0);
     float specular = pow(specAngle,specPower);
+    outputColor += vec4(lightColor,1) * specular;
+#endif
 
-    float rim = dot(viewDir, normal);
-
-
-    outputColor = color * diffuse + vec4(lightColor,1) * specular;
+#ifdef RIM
+    float rim = 1.0 - max(dot(-viewDir, normal), 0.0);
+    outputColor += vec4(lightColor,1) * pow(rim, 3.0);
+#endif
 }
\ No newline at end of file
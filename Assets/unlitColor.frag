@@ -1,6 +1,6 @@
 #version 330
 struct Material {
-    vec3 RGB;
+    vec3 RGB; // @color @default(1, 1, 1)
 };
 uniform Material material;
 
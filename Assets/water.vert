@@ -4,8 +4,8 @@ struct Material {
     vec3 a_wl_s_2;
     vec2 dir1;
     vec2 dir2;
-    float steepness1;
-    float steepness2;
+    float steepness1; // @range(0,1) @default(0.5)
+    float steepness2; // @range(0,1) @default(0.5)
     sampler2D tex;
 };
 
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/go-gl/gl/v3.2-core/gl"
+	"github.com/inkyblackness/imgui-go"
+)
+
+// Interpolation modes of a keyframe, used from the keyframe up to the next one
+const (
+	interpLinear string = "linear"
+	interpStep   string = "step"
+	interpBezier string = "bezier"
+)
+
+var interpolations = []string{interpLinear, interpStep, interpBezier}
+
+// globalTrackPrefix marks the tracks that animate a global render property instead of a material field
+const globalTrackPrefix = "global."
+
+// animatedGlobals are the global render properties a track can target
+var animatedGlobals = []string{"LightDir", "LightColor"}
+
+// defaultAnimationLength is the timeline length of new materials, in seconds
+const defaultAnimationLength = 5
+
+// defaultBezierHandles ease in and out
+var defaultBezierHandles = []float32{0.42, 0, 0.58, 1}
+
+// keyframe is the value of a track at a point in time
+type keyframe struct {
+	Time          float32   `json:"time"`
+	Value         []float32 `json:"value"`
+	Interpolation string    `json:"interpolation"`
+	// Handles are the control points x1, y1, x2, y2 of the bezier timing curve up to the next keyframe
+	Handles []float32 `json:"handles,omitempty"`
+}
+
+// animationTrack holds the keyframes of one material field or global property, ordered by time
+type animationTrack struct {
+	Target string     `json:"target"`
+	Keys   []keyframe `json:"keys"`
+}
+
+// animation is the keyframed part of a material and is saved with it
+type animation struct {
+	Length float32          `json:"length"`
+	Loop   bool             `json:"loop"`
+	Tracks []animationTrack `json:"tracks"`
+}
+
+// globalComponents returns the components of a global render property that tracks can animate
+func globalComponents(name string) []*float32 {
+	switch name {
+	case "LightDir":
+		v := &GlobalRenderProps.LightDir
+		return []*float32{&v[0], &v[1], &v[2]}
+	case "LightColor":
+		v := &GlobalRenderProps.LightColor
+		return []*float32{&v[0], &v[1], &v[2]}
+	}
+	return nil
+}
+
+// animatable tells if a field can be keyframed. Textures can't be interpolated.
+func animatable(field materialField) bool {
+	return !field.fieldType().isTexture()
+}
+
+// animationTargets lists the material fields and global properties that can get a track
+func animationTargets(m *material) []string {
+	targets := make([]string, 0)
+	for _, field := range m.fields {
+		if animatable(field) {
+			targets = append(targets, field.fieldName())
+		}
+	}
+	for _, name := range animatedGlobals {
+		targets = append(targets, globalTrackPrefix+name)
+	}
+	return targets
+}
+
+// currentValue returns the value the target has now, or nil if it doesn't exist
+func currentValue(m *material, target string) []float32 {
+	if strings.HasPrefix(target, globalTrackPrefix) {
+		components := globalComponents(strings.TrimPrefix(target, globalTrackPrefix))
+		if components == nil {
+			return nil
+		}
+		value := make([]float32, len(components))
+		for i, component := range components {
+			value[i] = *component
+		}
+		return value
+	}
+
+	if field := m.findField(target); field != nil && animatable(field) {
+		return field.savedValue().Value
+	}
+	return nil
+}
+
+// findTrack returns the track of the target, or nil
+func (a *animation) findTrack(target string) *animationTrack {
+	for i := range a.Tracks {
+		if a.Tracks[i].Target == target {
+			return &a.Tracks[i]
+		}
+	}
+	return nil
+}
+
+// setKey adds a keyframe, replacing the one at the same time
+func (t *animationTrack) setKey(key keyframe) {
+	for i := range t.Keys {
+		if math.Abs(float64(t.Keys[i].Time-key.Time)) < 1e-3 {
+			t.Keys[i] = key
+			return
+		}
+	}
+	t.Keys = append(t.Keys, key)
+	t.sortKeys()
+}
+
+func (t *animationTrack) sortKeys() {
+	sort.SliceStable(t.Keys, func(i, j int) bool {
+		return t.Keys[i].Time < t.Keys[j].Time
+	})
+}
+
+// sample returns the value of the track at the given time. Before the first and after the last
+// keyframe the track holds their value.
+func (t *animationTrack) sample(time float32) []float32 {
+	if len(t.Keys) == 0 {
+		return nil
+	}
+
+	last := t.Keys[len(t.Keys)-1]
+	if time >= last.Time {
+		return append([]float32(nil), last.Value...)
+	}
+	for i := len(t.Keys) - 2; i >= 0; i-- {
+		from, to := t.Keys[i], t.Keys[i+1]
+		if time >= from.Time {
+			return interpolateKeys(from, to, (time-from.Time)/(to.Time-from.Time))
+		}
+	}
+	return append([]float32(nil), t.Keys[0].Value...)
+}
+
+// interpolateKeys blends two keyframe values with the interpolation of the first one
+func interpolateKeys(from keyframe, to keyframe, progress float32) []float32 {
+	switch from.Interpolation {
+	case interpStep:
+		progress = 0
+	case interpBezier:
+		handles := from.Handles
+		if len(handles) != 4 {
+			handles = defaultBezierHandles
+		}
+		progress = bezierEase(handles, progress)
+	}
+
+	value := make([]float32, len(from.Value))
+	for i := range value {
+		target := from.Value[i]
+		if i < len(to.Value) {
+			target = to.Value[i]
+		}
+		value[i] = from.Value[i] + (target-from.Value[i])*progress
+	}
+	return value
+}
+
+// bezierEase maps linear progress through the cubic bezier timing curve from (0, 0) to (1, 1)
+// with the control points (x1, y1) and (x2, y2)
+func bezierEase(handles []float32, x float32) float32 {
+	// x(t) is monotonic while x1 and x2 stay within [0, 1], so bisection finds the curve parameter
+	low, high := float32(0), float32(1)
+	t := x
+	for i := 0; i < 24; i++ {
+		t = (low + high) / 2
+		if cubicBezier(handles[0], handles[2], t) < x {
+			low = t
+		} else {
+			high = t
+		}
+	}
+	return cubicBezier(handles[1], handles[3], t)
+}
+
+// cubicBezier evaluates one coordinate of a bezier curve starting at 0 and ending at 1
+func cubicBezier(p1 float32, p2 float32, t float32) float32 {
+	u := 1 - t
+	return 3*u*u*t*p1 + 3*u*t*t*p2 + t*t*t
+}
+
+// apply sets the sampled values on the targeted material fields and global properties.
+// It returns the material fields that changed, so only their uniforms are uploaded.
+func (a *animation) apply(m *material, time float32) []materialField {
+	changed := make([]materialField, 0)
+	for i := range a.Tracks {
+		track := &a.Tracks[i]
+		value := track.sample(time)
+		if value == nil {
+			continue
+		}
+
+		if strings.HasPrefix(track.Target, globalTrackPrefix) {
+			for j, component := range globalComponents(strings.TrimPrefix(track.Target, globalTrackPrefix)) {
+				if j < len(value) {
+					*component = value[j]
+				}
+			}
+			continue
+		}
+
+		// Tracks of fields the shader no longer declares stay in the file but do nothing
+		field := m.findField(track.Target)
+		if field == nil || !animatable(field) {
+			continue
+		}
+		field.setValue(fieldValue{Name: field.fieldName(), Type: field.fieldType(), Value: value})
+		changed = append(changed, field)
+	}
+	return changed
+}
+
+// timeline is the playback state of the timeline panel
+type timeline struct {
+	time    float32
+	playing bool
+	// scrubbed is set when the time was moved by hand and the values need to be sampled again
+	scrubbed      bool
+	selectedTrack int
+	newTarget     string
+	interpolation string
+}
+
+// advance moves the playhead, wrapping around at the end when looping and stopping otherwise
+func (tl *timeline) advance(elapsed float32, anim *animation) {
+	if !tl.playing {
+		return
+	}
+
+	tl.time += elapsed
+	if tl.time <= anim.Length {
+		return
+	}
+	if anim.Loop && anim.Length > 0 {
+		tl.time = float32(math.Mod(float64(tl.time), float64(anim.Length)))
+	} else {
+		tl.time = anim.Length
+		tl.playing = false
+	}
+}
+
+// update advances the playhead and applies the animation of the material while playing or scrubbing
+func (tl *timeline) update(elapsed float32, m *material) {
+	tl.advance(elapsed, &m.animation)
+	if !tl.playing && !tl.scrubbed {
+		return
+	}
+	tl.scrubbed = false
+
+	// Only the animated fields are uploaded, the others, such as textures, are left as they are
+	changed := m.animation.apply(m, tl.time)
+	if len(changed) == 0 {
+		return
+	}
+	gl.UseProgram(m.shader.program)
+	for _, field := range changed {
+		field.apply(m)
+	}
+}
+
+// drawUI draws the playback controls and the tracks of the material
+func (tl *timeline) drawUI(m *material) {
+	anim := &m.animation
+	if tl.interpolation == "" {
+		tl.interpolation = interpLinear
+	}
+
+	playLabel := "Play"
+	if tl.playing {
+		playLabel = "Pause"
+	}
+	if imgui.Button(playLabel) {
+		tl.playing = !tl.playing
+		if tl.playing && tl.time >= anim.Length {
+			tl.time = 0
+		}
+	}
+	imgui.SameLine()
+	if imgui.Button("Stop") {
+		tl.playing = false
+		tl.time = 0
+		tl.scrubbed = true
+	}
+	imgui.SameLine()
+	imgui.Checkbox("Loop", &anim.Loop)
+
+	imgui.DragFloatV("Length", &anim.Length, 0.1, 0.1, 600, "%.1f s", 1)
+	if imgui.SliderFloat("Time", &tl.time, 0, anim.Length) {
+		tl.scrubbed = true
+	}
+	tl.drawStrip(anim)
+	imgui.Separator()
+
+	drawComboString("##trackTarget", &tl.newTarget, animationTargets(m))
+	imgui.SameLine()
+	if imgui.Button("Add track") && tl.newTarget != "" && anim.findTrack(tl.newTarget) == nil {
+		anim.Tracks = append(anim.Tracks, animationTrack{Target: tl.newTarget})
+		tl.selectedTrack = len(anim.Tracks) - 1
+	}
+
+	for i, track := range anim.Tracks {
+		if imgui.SelectableV(fmt.Sprintf("%s (%d keys)##track%d", track.Target, len(track.Keys), i), i == tl.selectedTrack, 0, imgui.Vec2{}) {
+			tl.selectedTrack = i
+		}
+	}
+	if tl.selectedTrack >= len(anim.Tracks) {
+		return
+	}
+	imgui.Separator()
+	tl.drawTrackUI(m, tl.selectedTrack)
+}
+
+// drawStrip draws the playhead as a progress bar with a marker per keyframe
+func (tl *timeline) drawStrip(anim *animation) {
+	origin := imgui.CursorScreenPos()
+	width := imgui.ContentRegionAvail().X
+	fraction := float32(0)
+	if anim.Length > 0 {
+		fraction = tl.time / anim.Length
+	}
+	imgui.ProgressBar(fraction)
+	if anim.Length <= 0 {
+		return
+	}
+
+	drawList := imgui.WindowDrawList()
+	height := imgui.TextLineHeight()
+	for i, track := range anim.Tracks {
+		color := imgui.PackedColorFromVec4(imgui.Vec4{X: 0.6, Y: 0.6, Z: 0.6, W: 1})
+		if i == tl.selectedTrack {
+			color = imgui.PackedColorFromVec4(imgui.Vec4{X: 1, Y: 0.8, Z: 0.2, W: 1})
+		}
+		for _, key := range track.Keys {
+			x := origin.X + width*key.Time/anim.Length
+			drawList.AddRectFilled(imgui.Vec2{X: x - 1, Y: origin.Y}, imgui.Vec2{X: x + 1, Y: origin.Y + height}, color, 0, 0)
+		}
+	}
+}
+
+// drawTrackUI draws the keyframes of a track and the buttons to add keys and remove the track
+func (tl *timeline) drawTrackUI(m *material, index int) {
+	anim := &m.animation
+	track := &anim.Tracks[index]
+
+	drawComboString("Interpolation", &tl.interpolation, interpolations)
+	if imgui.Button("Key current value") {
+		if value := currentValue(m, track.Target); value != nil {
+			track.setKey(keyframe{Time: tl.time, Value: value, Interpolation: tl.interpolation})
+		}
+	}
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Store the current value of " + track.Target + " at the playhead")
+	}
+	imgui.SameLine()
+	if imgui.Button("Remove track") {
+		anim.Tracks = append(anim.Tracks[:index], anim.Tracks[index+1:]...)
+		return
+	}
+
+	// Keys are sorted once no time is being dragged, so the dragged key keeps its row
+	dragging := false
+	defer func() {
+		if !dragging {
+			track.sortKeys()
+		}
+	}()
+
+	for i := 0; i < len(track.Keys); i++ {
+		key := &track.Keys[i]
+		imgui.PushID(fmt.Sprintf("key%d", i))
+
+		if imgui.DragFloatV("time", &key.Time, 0.01, 0, anim.Length, "%.2f s", 1) {
+			tl.scrubbed = true
+		}
+		dragging = dragging || imgui.IsItemActive()
+		if drawComboString("interpolation", &key.Interpolation, interpolations) {
+			tl.scrubbed = true
+		}
+		if key.Interpolation == interpBezier {
+			if len(key.Handles) != 4 {
+				key.Handles = append([]float32(nil), defaultBezierHandles...)
+			}
+			imgui.Columns(4, "handles")
+			for j, label := range []string{"x1", "y1", "x2", "y2"} {
+				min, max := float32(-1), float32(2)
+				if j%2 == 0 {
+					// The curve has to move forward in time
+					min, max = 0, 1
+				}
+				if imgui.SliderFloat(label, &key.Handles[j], min, max) {
+					tl.scrubbed = true
+				}
+				imgui.NextColumn()
+			}
+			imgui.Columns(1, "")
+		}
+		imgui.Text(fmt.Sprint(key.Value))
+		imgui.SameLine()
+		remove := imgui.Button("Delete key")
+		imgui.Separator()
+		imgui.PopID()
+
+		if remove {
+			track.Keys = append(track.Keys[:i], track.Keys[i+1:]...)
+			tl.scrubbed = true
+			break
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestTrackSample(t *testing.T) {
+	track := animationTrack{Target: "tint"}
+	track.setKey(keyframe{Time: 2, Value: []float32{10, 20}, Interpolation: interpStep})
+	track.setKey(keyframe{Time: 0, Value: []float32{0, 0}, Interpolation: interpLinear})
+	track.setKey(keyframe{Time: 4, Value: []float32{0, 0}})
+	assert.Equal(t, len(track.Keys), 3)
+	assert.Equal(t, track.Keys[1].Time, float32(2))
+
+	assert.DeepEqual(t, track.sample(-1), []float32{0, 0})
+	assert.DeepEqual(t, track.sample(1), []float32{5, 10})
+	assert.DeepEqual(t, track.sample(3), []float32{10, 20})
+	assert.DeepEqual(t, track.sample(5), []float32{0, 0})
+
+	// A key at the same time replaces the old one
+	track.setKey(keyframe{Time: 4, Value: []float32{1, 1}})
+	assert.Equal(t, len(track.Keys), 3)
+	assert.DeepEqual(t, track.sample(4), []float32{1, 1})
+
+	assert.Assert(t, (&animationTrack{}).sample(1) == nil)
+}
+
+func TestBezierEase(t *testing.T) {
+	// Handles on the diagonal give a straight line
+	assert.Assert(t, abs32(bezierEase([]float32{0.25, 0.25, 0.75, 0.75}, 0.3)-0.3) < 1e-3)
+
+	// Ease in and out is slow at the ends and symmetric
+	assert.Assert(t, bezierEase(defaultBezierHandles, 0.1) < 0.1)
+	assert.Assert(t, abs32(bezierEase(defaultBezierHandles, 0.5)-0.5) < 1e-3)
+	assert.Assert(t, bezierEase(defaultBezierHandles, 0.9) > 0.9)
+
+	from := keyframe{Time: 0, Value: []float32{0}, Interpolation: interpBezier}
+	to := keyframe{Time: 1, Value: []float32{2}}
+	assert.Assert(t, abs32(interpolateKeys(from, to, 0.5)[0]-1) < 1e-3)
+}
+
+func abs32(x float32) float32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func TestTimelineAdvance(t *testing.T) {
+	anim := animation{Length: 2, Loop: true}
+	tl := timeline{playing: true, time: 1.5}
+	tl.advance(1, &anim)
+	assert.Equal(t, tl.time, float32(0.5))
+	assert.Equal(t, tl.playing, true)
+
+	anim.Loop = false
+	tl.advance(2, &anim)
+	assert.Equal(t, tl.time, float32(2))
+	assert.Equal(t, tl.playing, false)
+
+	// Paused timelines stay put
+	tl.advance(1, &anim)
+	assert.Equal(t, tl.time, float32(2))
+}
+
+func TestAnimationApply(t *testing.T) {
+	lightColor := GlobalRenderProps.LightColor
+	defer func() { GlobalRenderProps.LightColor = lightColor }()
+
+	mat := material{fields: []materialField{
+		&matFieldFloat{name: "roughness"},
+		&matFieldTexture{name: "albedo", filePath: "Assets/wood.png"},
+	}}
+	mat.animation = animation{Length: 2, Tracks: []animationTrack{
+		{Target: "roughness", Keys: []keyframe{{Time: 0, Value: []float32{0}}, {Time: 2, Value: []float32{1}}}},
+		{Target: "global.LightColor", Keys: []keyframe{{Time: 0, Value: []float32{1, 0, 0}}}},
+		{Target: "removed", Keys: []keyframe{{Time: 0, Value: []float32{1}}}},
+	}}
+
+	changed := mat.animation.apply(&mat, 0.5)
+	assert.Equal(t, len(changed), 1)
+	assert.Equal(t, changed[0].fieldName(), "roughness")
+	assert.Equal(t, mat.fields[0].(*matFieldFloat).value, float32(0.25))
+	assert.Equal(t, GlobalRenderProps.LightColor, [3]float32{1, 0, 0})
+	assert.DeepEqual(t, animationTargets(&mat), []string{"roughness", "global.LightDir", "global.LightColor"})
+	assert.DeepEqual(t, currentValue(&mat, "global.LightColor"), []float32{1, 0, 0})
+	assert.Assert(t, currentValue(&mat, "albedo") == nil)
+
+	// The keyframes are saved with the material
+	file := mat.file()
+	assert.Equal(t, len(file.Animation.Tracks), 3)
+	assert.Equal(t, file.Animation.Length, float32(2))
+	assert.Assert(t, (&material{}).file().Animation == nil)
+}
+
+func TestAnimationSkipsBrokenTexture(t *testing.T) {
+	albedo := &matFieldTexture{name: "albedo", filePath: "Assets/missing.png"}
+	mat := material{fields: []materialField{&matFieldFloat{name: "roughness"}, albedo}}
+	mat.animation = animation{Length: 1, Loop: true, Tracks: []animationTrack{
+		{Target: "roughness", Keys: []keyframe{{Time: 0, Value: []float32{0}}, {Time: 1, Value: []float32{1}}}},
+	}}
+
+	// The failed load is remembered, and playing only hands the animated field over for upload
+	albedo.apply(&mat)
+	failed := albedo.failed
+	assert.Equal(t, failed.path, "Assets/missing.png")
+
+	tl := timeline{playing: true}
+	for frame := 0; frame < 10; frame++ {
+		tl.advance(0.25, &mat.animation)
+		for _, field := range mat.animation.apply(&mat, tl.time) {
+			assert.Equal(t, field.fieldName(), "roughness")
+		}
+	}
+	assert.Equal(t, albedo.failed, failed)
+	assert.Equal(t, albedo.tex.filePath, "")
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/go-gl/gl/v3.2-core/gl"
+	"github.com/inkyblackness/imgui-go"
+)
+
+// textureKey identifies a shared texture. The same image sampled differently, or loaded as a cubemap,
+// is a separate GL texture.
+type textureKey struct {
+	path    string
+	sampler samplerSettings
+	cube    bool
+}
+
+// textureAsset is a GL texture shared by every material that uses the same file and sampler settings
+type textureAsset struct {
+	key     textureKey
+	id      uint32
+	refs    int
+	width   int
+	height  int
+	bytes   int
+	modTime time.Time
+	// format is the GPU pixel format, e.g. RGBA8 or RGB16F
+	format string
+}
+
+// textureAssetManager loads every texture once, counts its users and reloads it when the file changes
+type textureAssetManager struct {
+	assets    map[textureKey]*textureAsset
+	lastCheck time.Time
+}
+
+// textureAssets is the manager shared by all materials
+var textureAssets = textureAssetManager{assets: make(map[textureKey]*textureAsset)}
+
+// How often the loaded files are checked for changes
+const assetCheckInterval = time.Second
+
+// acquire returns the asset of the file and sampler settings, loading it on first use. Every acquire
+// must be paired with a release.
+func (m *textureAssetManager) acquire(path string, sampler samplerSettings) (*textureAsset, error) {
+	return m.acquireKey(textureKey{path: path, sampler: sampler})
+}
+
+// acquireKey is acquire for 2D textures and cubemaps
+func (m *textureAssetManager) acquireKey(key textureKey) (*textureAsset, error) {
+	if asset, ok := m.assets[key]; ok {
+		asset.refs++
+		return asset, nil
+	}
+
+	// Decode first, so a bad file doesn't create a GL texture
+	images, modTime, err := readTextureAsset(key)
+	if err != nil {
+		return nil, err
+	}
+
+	asset := &textureAsset{key: key, refs: 1}
+	gl.GenTextures(1, &asset.id)
+	asset.upload(images, modTime)
+	m.assets[key] = asset
+	return asset, nil
+}
+
+// release drops a reference and deletes the GL texture once nothing uses it
+func (m *textureAssetManager) release(asset *textureAsset) {
+	asset.refs--
+	if asset.refs > 0 {
+		return
+	}
+
+	gl.DeleteTextures(1, &asset.id)
+	delete(m.assets, asset.key)
+}
+
+// readTextureFile decodes an image file and returns its modification time
+func readTextureFile(path string) (*textureImage, time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("texture %q not found on disk: %v", path, err)
+	}
+
+	img, err := decodeImage(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return img, info.ModTime(), nil
+}
+
+// readTextureAsset decodes the image of a 2D texture, or the six faces of a cubemap
+func readTextureAsset(key textureKey) ([]*textureImage, time.Time, error) {
+	if key.cube {
+		return readCubemap(key.path)
+	}
+	img, modTime, err := readTextureFile(key.path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return []*textureImage{img}, modTime, nil
+}
+
+// textureModTime returns the latest modification time of the files of a texture
+func textureModTime(key textureKey) (time.Time, error) {
+	if !key.cube {
+		info, err := os.Stat(key.path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return info.ModTime(), nil
+	}
+
+	files, err := cubemapFiles(key.path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return latestModTime(files)
+}
+
+// upload fills the GL texture of the asset with the decoded image or cubemap faces
+func (a *textureAsset) upload(images []*textureImage, modTime time.Time) {
+	img := images[0]
+	if a.key.cube {
+		uploadCubemap(a.id, images, a.key.sampler)
+	} else {
+		uploadTexture(a.id, img, a.key.sampler)
+	}
+
+	a.width = img.width
+	a.height = img.height
+	a.format = img.format().name
+	a.bytes = img.bytes() * len(images)
+	if a.key.cube {
+		a.format += " cube"
+	}
+	if a.key.sampler.Mipmaps {
+		// The mipmap chain adds about a third
+		a.bytes += a.bytes / 3
+	}
+	a.modTime = modTime
+}
+
+// reloadChanged reloads the textures whose files were modified. The GL texture names stay the same,
+// so the materials pick up the new image without rebinding. Files are checked at most once per interval.
+func (m *textureAssetManager) reloadChanged() {
+	if time.Since(m.lastCheck) < assetCheckInterval {
+		return
+	}
+	m.lastCheck = time.Now()
+
+	for _, asset := range m.assets {
+		changed, err := textureModTime(asset.key)
+		if err != nil || changed.Equal(asset.modTime) {
+			continue
+		}
+
+		images, modTime, err := readTextureAsset(asset.key)
+		if err != nil {
+			log.Printf("ERROR: reloading %s: %v", asset.key.path, err)
+			// Don't retry until the file changes again
+			asset.modTime = changed
+			continue
+		}
+		asset.upload(images, modTime)
+		log.Printf("Reloaded %s", asset.key.path)
+	}
+}
+
+// sorted returns the loaded assets ordered by path
+func (m *textureAssetManager) sorted() []*textureAsset {
+	assets := make([]*textureAsset, 0, len(m.assets))
+	for _, asset := range m.assets {
+		assets = append(assets, asset)
+	}
+	sort.Slice(assets, func(i, j int) bool {
+		if assets[i].key.path != assets[j].key.path {
+			return assets[i].key.path < assets[j].key.path
+		}
+		return assets[i].key.sampler.String() < assets[j].key.sampler.String()
+	})
+	return assets
+}
+
+// formatBytes returns a human readable memory size
+func formatBytes(bytes int) string {
+	switch {
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+	case bytes >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(bytes)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", bytes)
+	}
+}
+
+// drawAssetsGUI lists the loaded textures with their users and memory usage
+func drawAssetsGUI() {
+	total := 0
+	for _, asset := range textureAssets.assets {
+		total += asset.bytes
+	}
+	imgui.Text(fmt.Sprintf("%d textures, %s", len(textureAssets.assets), formatBytes(total)))
+
+	imgui.Columns(6, "assets")
+	imgui.Text("path")
+	imgui.NextColumn()
+	imgui.Text("sampler")
+	imgui.NextColumn()
+	imgui.Text("size")
+	imgui.NextColumn()
+	imgui.Text("format")
+	imgui.NextColumn()
+	imgui.Text("memory")
+	imgui.NextColumn()
+	imgui.Text("users")
+	imgui.NextColumn()
+	imgui.Separator()
+
+	for _, asset := range textureAssets.sorted() {
+		imgui.Text(asset.key.path)
+		imgui.NextColumn()
+		imgui.Text(asset.key.sampler.String())
+		imgui.NextColumn()
+		imgui.Text(fmt.Sprintf("%dx%d", asset.width, asset.height))
+		imgui.NextColumn()
+		imgui.Text(asset.format)
+		imgui.NextColumn()
+		imgui.Text(formatBytes(asset.bytes))
+		imgui.NextColumn()
+		imgui.Text(fmt.Sprint(asset.refs))
+		imgui.NextColumn()
+	}
+	imgui.Columns(1, "")
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestTextureAssetSharing(t *testing.T) {
+	manager := textureAssetManager{assets: make(map[textureKey]*textureAsset)}
+	key := textureKey{path: "Assets/wood.png", sampler: defaultSamplerSettings()}
+	loaded := &textureAsset{key: key, id: 3, refs: 1}
+	manager.assets[key] = loaded
+
+	// Same file and sampler share the loaded texture
+	asset, err := manager.acquire("Assets/wood.png", defaultSamplerSettings())
+	assert.NilError(t, err)
+	assert.Assert(t, asset == loaded)
+	assert.Equal(t, asset.refs, 2)
+
+	manager.release(asset)
+	assert.Equal(t, asset.refs, 1)
+	assert.Equal(t, len(manager.assets), 1)
+
+	// A missing file fails before any GL texture is created
+	_, err = manager.acquire("Assets/missing.png", defaultSamplerSettings())
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, formatBytes(512), "512 B")
+	assert.Equal(t, formatBytes(4096), "4.0 KB")
+	assert.Equal(t, formatBytes(3<<20), "3.0 MB")
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/go-gl/gl/v3.2-core/gl"
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// objectsPerFrame is the number of draw calls simulated per benchmark iteration
+const objectsPerFrame = 100
+
+// newBenchmarkContext creates a hidden window with a GL context and returns its cleanup function.
+// The benchmark is skipped when no display is available.
+func newBenchmarkContext(b *testing.B) func() {
+	runtime.LockOSThread()
+
+	if err := glfw.Init(); err != nil {
+		runtime.UnlockOSThread()
+		b.Skipf("glfw unavailable: %v", err)
+	}
+
+	glfw.WindowHint(glfw.Visible, glfw.False)
+	glfw.WindowHint(glfw.ContextVersionMajor, 3)
+	glfw.WindowHint(glfw.ContextVersionMinor, 2)
+	glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
+	glfw.WindowHint(glfw.OpenGLForwardCompatible, 1)
+
+	window, err := glfw.CreateWindow(64, 64, "benchmark", nil, nil)
+	if err != nil {
+		glfw.Terminate()
+		runtime.UnlockOSThread()
+		b.Skipf("no GL context: %v", err)
+	}
+	window.MakeContextCurrent()
+
+	if err := gl.Init(); err != nil {
+		window.Destroy()
+		glfw.Terminate()
+		runtime.UnlockOSThread()
+		b.Skipf("gl init failed: %v", err)
+	}
+
+	return func() {
+		// Program names are only unique per context
+		uniformTables = make(map[uint32]*uniformTable)
+		window.Destroy()
+		glfw.Terminate()
+		runtime.UnlockOSThread()
+	}
+}
+
+func newBenchmarkMaterial(b *testing.B) *material {
+	var s shader
+	if err := s.loadFromFile("Assets/cellShadeColor.vert", "Assets/cellShadeColor.frag", ""); err != nil {
+		b.Fatal(err)
+	}
+	var mat material
+	mat.init(s)
+	return &mat
+}
+
+// BenchmarkUniformLookupPerFrame measures the uploads of one frame when every location is looked up by name,
+// as the material fields and built-ins did before the location tables
+func BenchmarkUniformLookupPerFrame(b *testing.B) {
+	defer newBenchmarkContext(b)()
+	mat := newBenchmarkMaterial(b)
+	program := mat.shader.program
+	ctx := objectContext{mgl32.Ident4(), mgl32.Ident4(), mgl32.Ident4()}
+	gl.UseProgram(program)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, builtin := range builtinUniforms {
+			if builtin.scope != scopeFrame {
+				continue
+			}
+			if location := gl.GetUniformLocation(program, gl.Str(builtin.name+"\x00")); location >= 0 {
+				setUniformValue(location, builtin.provide(nil))
+			}
+		}
+
+		for object := 0; object < objectsPerFrame; object++ {
+			for _, field := range mat.fields {
+				if _, ok := field.(*matFieldTexture); !ok {
+					gl.GetUniformLocation(program, gl.Str("material."+field.fieldName()+"\x00"))
+					field.apply(mat)
+				}
+			}
+			for _, builtin := range builtinUniforms {
+				if builtin.scope != scopeObject {
+					continue
+				}
+				if location := gl.GetUniformLocation(program, gl.Str(builtin.name+"\x00")); location >= 0 {
+					setUniformValue(location, builtin.provide(&ctx))
+				}
+			}
+		}
+	}
+}
+
+// BenchmarkCachedUniformsPerFrame measures the same uploads with the cached location tables
+func BenchmarkCachedUniformsPerFrame(b *testing.B) {
+	defer newBenchmarkContext(b)()
+	mat := newBenchmarkMaterial(b)
+	ctx := objectContext{mgl32.Ident4(), mgl32.Ident4(), mgl32.Ident4()}
+	gl.UseProgram(mat.shader.program)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mat.uniforms.applyBuiltins(scopeFrame, nil)
+
+		for object := 0; object < objectsPerFrame; object++ {
+			for _, field := range mat.fields {
+				if _, ok := field.(*matFieldTexture); !ok {
+					field.apply(mat)
+				}
+			}
+			mat.uniforms.applyBuiltins(scopeObject, &ctx)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"github.com/go-gl/gl/v3.2-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/inkyblackness/imgui-go"
+)
+
+// builtinScope tells when a built-in uniform is uploaded
+type builtinScope int
+
+const (
+	// scopeFrame uniforms are uploaded once per frame by ApplyGlobalRenderProperties
+	scopeFrame builtinScope = iota
+	// scopeObject uniforms depend on the object matrices and are uploaded with every draw call
+	scopeObject
+)
+
+// objectContext holds the matrices of the object being drawn
+type objectContext struct {
+	model      mgl32.Mat4
+	view       mgl32.Mat4
+	projection mgl32.Mat4
+}
+
+// builtinUniform is an engine provided uniform. The provider returns a float32, int32,
+// mgl32.Vec2, mgl32.Vec3, mgl32.Mat3 or mgl32.Mat4 matching the GLSL type.
+type builtinUniform struct {
+	name    string
+	uType   uniformType
+	scope   builtinScope
+	provide func(ctx *objectContext) interface{}
+}
+
+var builtinUniforms []builtinUniform
+
+// registerBuiltinUniform adds an engine uniform to the registry. Registering a name twice replaces the previous entry.
+func registerBuiltinUniform(name string, uType uniformType, scope builtinScope, provide func(ctx *objectContext) interface{}) {
+	builtin := builtinUniform{name, uType, scope, provide}
+	for i := range builtinUniforms {
+		if builtinUniforms[i].name == name {
+			builtinUniforms[i] = builtin
+			return
+		}
+	}
+	builtinUniforms = append(builtinUniforms, builtin)
+}
+
+// findBuiltinUniform returns the registered built-in with the given name
+func findBuiltinUniform(name string) (builtinUniform, bool) {
+	for _, builtin := range builtinUniforms {
+		if builtin.name == name {
+			return builtin, true
+		}
+	}
+	return builtinUniform{}, false
+}
+
+func init() {
+	// Per frame properties
+	registerBuiltinUniform(timeName, uniformFloat, scopeFrame, func(ctx *objectContext) interface{} {
+		return GlobalRenderProps.Time
+	})
+	registerBuiltinUniform(deltaTimeName, uniformFloat, scopeFrame, func(ctx *objectContext) interface{} {
+		return GlobalRenderProps.DeltaTime
+	})
+	registerBuiltinUniform(frameIndexName, uniformInt, scopeFrame, func(ctx *objectContext) interface{} {
+		return GlobalRenderProps.FrameIndex
+	})
+	registerBuiltinUniform(resolutionName, uniformVec2, scopeFrame, func(ctx *objectContext) interface{} {
+		return mgl32.Vec2(GlobalRenderProps.Resolution)
+	})
+	registerBuiltinUniform(mouseName, uniformVec2, scopeFrame, func(ctx *objectContext) interface{} {
+		return mgl32.Vec2(GlobalRenderProps.Mouse)
+	})
+	registerBuiltinUniform(lightDirName, uniformVec3, scopeFrame, func(ctx *objectContext) interface{} {
+		return mgl32.Vec3(GlobalRenderProps.LightDir)
+	})
+	registerBuiltinUniform(lightColorName, uniformVec3, scopeFrame, func(ctx *objectContext) interface{} {
+		return mgl32.Vec3(GlobalRenderProps.LightColor)
+	})
+	registerBuiltinUniform(camWorldPosName, uniformVec3, scopeFrame, func(ctx *objectContext) interface{} {
+		return mgl32.Vec3(GlobalRenderProps.CameraPos)
+	})
+	registerBuiltinUniform(cameraNearName, uniformFloat, scopeFrame, func(ctx *objectContext) interface{} {
+		return GlobalRenderProps.CameraNear
+	})
+	registerBuiltinUniform(cameraFarName, uniformFloat, scopeFrame, func(ctx *objectContext) interface{} {
+		return GlobalRenderProps.CameraFar
+	})
+
+	// Per object matrices
+	registerBuiltinUniform(modelMatrixName, uniformMat4, scopeObject, func(ctx *objectContext) interface{} {
+		return ctx.model
+	})
+	registerBuiltinUniform(viewMatrixName, uniformMat4, scopeObject, func(ctx *objectContext) interface{} {
+		return ctx.view
+	})
+	registerBuiltinUniform(projMatrixName, uniformMat4, scopeObject, func(ctx *objectContext) interface{} {
+		return ctx.projection
+	})
+	registerBuiltinUniform(mvpMatrixName, uniformMat4, scopeObject, func(ctx *objectContext) interface{} {
+		return ctx.projection.Mul4(ctx.view.Mul4(ctx.model))
+	})
+	registerBuiltinUniform(invModelMatrixName, uniformMat4, scopeObject, func(ctx *objectContext) interface{} {
+		return ctx.model.Inv()
+	})
+	registerBuiltinUniform(invViewMatrixName, uniformMat4, scopeObject, func(ctx *objectContext) interface{} {
+		return ctx.view.Inv()
+	})
+	registerBuiltinUniform(invProjMatrixName, uniformMat4, scopeObject, func(ctx *objectContext) interface{} {
+		return ctx.projection.Inv()
+	})
+	registerBuiltinUniform(normalMatrixName, uniformMat3, scopeObject, func(ctx *objectContext) interface{} {
+		return ctx.model.Mat3().Inv().Transpose()
+	})
+}
+
+// applyBuiltinUniforms uploads every built-in of the given scope to the program currently in use
+func applyBuiltinUniforms(program uint32, scope builtinScope, ctx *objectContext) {
+	lookupUniformTable(program).applyBuiltins(scope, ctx)
+}
+
+// applyBuiltinUniform uploads a single built-in to the program currently in use
+func applyBuiltinUniform(program uint32, name string, ctx *objectContext) {
+	builtin, ok := findBuiltinUniform(name)
+	if !ok {
+		return
+	}
+	location := lookupUniformTable(program).location(builtin.name)
+	setUniformValue(location, builtin.provide(ctx))
+}
+
+func setUniformValue(location int32, value interface{}) {
+	switch v := value.(type) {
+	case float32:
+		gl.Uniform1f(location, v)
+	case int32:
+		gl.Uniform1i(location, v)
+	case mgl32.Vec2:
+		gl.Uniform2f(location, v[0], v[1])
+	case mgl32.Vec3:
+		gl.Uniform3f(location, v[0], v[1], v[2])
+	case mgl32.Vec4:
+		gl.Uniform4f(location, v[0], v[1], v[2], v[3])
+	case mgl32.Mat3:
+		gl.UniformMatrix3fv(location, 1, false, &v[0])
+	case mgl32.Mat4:
+		gl.UniformMatrix4fv(location, 1, false, &v[0])
+	}
+}
+
+// consumedBuiltins returns the registered built-ins declared as uniforms by the given stage sources
+func consumedBuiltins(sources []string) []builtinUniform {
+	consumed := make([]builtinUniform, 0)
+	for _, builtin := range builtinUniforms {
+		declared := false
+		for _, source := range sources {
+			for _, decl := range parseDeclarations(source) {
+				if decl.qualifier == "uniform" && decl.name == builtin.name {
+					declared = true
+				}
+			}
+		}
+		if declared {
+			consumed = append(consumed, builtin)
+		}
+	}
+	return consumed
+}
+
+// drawBuiltinsGUI lists the built-in uniforms consumed by the active shader
+func drawBuiltinsGUI(s *shader) {
+	imgui.Text("Built-in uniforms used by the active shader")
+	if len(s.builtins) == 0 {
+		imgui.Text("	none")
+	}
+
+	imgui.Columns(3, "builtins")
+	for _, builtin := range s.builtins {
+		imgui.Text(builtin.name)
+		imgui.NextColumn()
+		imgui.Text(string(builtin.uType))
+		imgui.NextColumn()
+		if builtin.scope == scopeFrame {
+			imgui.Text("per frame")
+		} else {
+			imgui.Text("per object")
+		}
+		imgui.NextColumn()
+	}
+	imgui.Columns(1, "")
+
+	if imgui.CollapsingHeader("Globals uniform block") {
+		imgui.Text("Declare this block to read the per frame properties from a shared uniform buffer")
+		source := globalsBlockSource()
+		imgui.InputTextMultilineV("##globalsBlock", &source, imgui.Vec2{X: -1, Y: 200}, imgui.InputTextFlagsReadOnly, nil)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-gl/gl/v3.2-core/gl"
+)
+
+// cubeFaceNames are the file name conventions of the six faces, in the order of the
+// TEXTURE_CUBE_MAP_POSITIVE_X based targets: +X, -X, +Y, -Y, +Z, -Z
+var cubeFaceNames = [][6]string{
+	{"px", "nx", "py", "ny", "pz", "nz"},
+	{"posx", "negx", "posy", "negy", "posz", "negz"},
+	{"right", "left", "top", "bottom", "front", "back"},
+}
+
+// cubemapFiles returns the face files of a cubemap path. A * in the path stands for the face names
+// of the first convention whose six files exist, any other path is a single cross or panorama image.
+func cubemapFiles(path string) ([]string, error) {
+	if !strings.Contains(path, "*") {
+		return []string{path}, nil
+	}
+	for _, names := range cubeFaceNames {
+		files := make([]string, 0, 6)
+		for _, name := range names {
+			file := strings.Replace(path, "*", name, 1)
+			if _, err := os.Stat(file); err != nil {
+				break
+			}
+			files = append(files, file)
+		}
+		if len(files) == 6 {
+			return files, nil
+		}
+	}
+	return nil, fmt.Errorf("cubemap %q: no six face files found, name them px, nx, py, ny, pz and nz", path)
+}
+
+// latestModTime returns the modification time of the most recently changed file
+func latestModTime(files []string) (time.Time, error) {
+	var latest time.Time
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("texture %q not found on disk: %v", file, err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// readCubemap decodes the six faces of a cubemap. Unlike 2D textures, cubemap faces are uploaded
+// with their top row first.
+func readCubemap(path string) ([]*textureImage, time.Time, error) {
+	files, err := cubemapFiles(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	modTime, err := latestModTime(files)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	images := make([]*textureImage, 0, len(files))
+	for _, file := range files {
+		img, err := decodeImage(file)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		img.flip()
+		images = append(images, img)
+	}
+
+	faces := images
+	if len(images) == 1 {
+		if faces, err = splitCubemap(images[0]); err != nil {
+			return nil, time.Time{}, fmt.Errorf("cubemap %q: %v", path, err)
+		}
+	}
+	for i, face := range faces {
+		if face.width != face.height || face.width != faces[0].width || face.format() != faces[0].format() {
+			return nil, time.Time{}, fmt.Errorf("cubemap %q: face %d isn't square or differs from the first face", path, i+1)
+		}
+	}
+	return faces, modTime, nil
+}
+
+// splitCubemap cuts a 4:3 horizontal or 3:4 vertical cross into faces, or projects a 2:1
+// equirectangular panorama onto them
+func splitCubemap(img *textureImage) ([]*textureImage, error) {
+	switch {
+	case img.width*3 == img.height*4:
+		//     +Y
+		// -X  +Z  +X  -Z
+		//     -Y
+		size := img.width / 4
+		cells := [6][2]int{{2, 1}, {0, 1}, {1, 0}, {1, 2}, {1, 1}, {3, 1}}
+		faces := make([]*textureImage, 6)
+		for i, cell := range cells {
+			faces[i] = img.crop(cell[0]*size, cell[1]*size, size)
+		}
+		return faces, nil
+	case img.width*4 == img.height*3:
+		// The vertical cross has -Z below -Y, upside down
+		size := img.width / 3
+		cells := [6][2]int{{2, 1}, {0, 1}, {1, 0}, {1, 2}, {1, 1}, {1, 3}}
+		faces := make([]*textureImage, 6)
+		for i, cell := range cells {
+			faces[i] = img.crop(cell[0]*size, cell[1]*size, size)
+		}
+		faces[5].rotate180()
+		return faces, nil
+	case img.width == img.height*2:
+		return equirectToCubemap(img, img.width/4), nil
+	}
+	return nil, fmt.Errorf("%dx%d is neither a 4:3 or 3:4 cross nor a 2:1 panorama", img.width, img.height)
+}
+
+// newTextureImageLike returns a black image with the channels and precision of img
+func newTextureImageLike(img *textureImage, width, height int) *textureImage {
+	blank := &textureImage{width: width, height: height, channels: img.channels}
+	if img.floats != nil {
+		blank.floats = make([]float32, width*height*img.channels)
+	} else {
+		blank.pixels = make([]uint8, width*height*img.channels)
+	}
+	return blank
+}
+
+// value returns a channel of a pixel, in 0 to 255 for LDR images
+func (img *textureImage) value(x, y, channel int) float32 {
+	i := (y*img.width+x)*img.channels + channel
+	if img.floats != nil {
+		return img.floats[i]
+	}
+	return float32(img.pixels[i])
+}
+
+func (img *textureImage) setValue(x, y, channel int, value float32) {
+	i := (y*img.width+x)*img.channels + channel
+	if img.floats != nil {
+		img.floats[i] = value
+		return
+	}
+	img.pixels[i] = uint8(math.Max(0, math.Min(255, math.Floor(float64(value)+0.5))))
+}
+
+// crop copies a square of the given size
+func (img *textureImage) crop(left, top, size int) *textureImage {
+	face := newTextureImageLike(img, size, size)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			for c := 0; c < img.channels; c++ {
+				face.setValue(x, y, c, img.value(left+x, top+y, c))
+			}
+		}
+	}
+	return face
+}
+
+// rotate180 turns the image upside down in place
+func (img *textureImage) rotate180() {
+	last := img.width*img.height - 1
+	for i := 0; i < (last+1)/2; i++ {
+		x, y := i%img.width, i/img.width
+		ox, oy := (last-i)%img.width, (last-i)/img.width
+		for c := 0; c < img.channels; c++ {
+			a, b := img.value(x, y, c), img.value(ox, oy, c)
+			img.setValue(x, y, c, b)
+			img.setValue(ox, oy, c, a)
+		}
+	}
+}
+
+// cubeFaceDirection returns the direction a texel of a face points to. s goes right and t down
+// the face, both from -1 to 1, following the cubemap conventions of OpenGL.
+func cubeFaceDirection(face int, s, t float64) (float64, float64, float64) {
+	switch face {
+	case 0:
+		return 1, -t, -s
+	case 1:
+		return -1, -t, s
+	case 2:
+		return s, 1, t
+	case 3:
+		return s, -1, -t
+	case 4:
+		return s, -t, 1
+	default:
+		return -s, -t, -1
+	}
+}
+
+// equirectToCubemap projects a panorama onto six faces of the given size. -Z looks at the middle of
+// the panorama and +Y at its top row.
+func equirectToCubemap(img *textureImage, size int) []*textureImage {
+	faces := make([]*textureImage, 6)
+	for i := range faces {
+		face := newTextureImageLike(img, size, size)
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				s := 2*(float64(x)+0.5)/float64(size) - 1
+				t := 2*(float64(y)+0.5)/float64(size) - 1
+				dx, dy, dz := cubeFaceDirection(i, s, t)
+				length := math.Sqrt(dx*dx + dy*dy + dz*dz)
+
+				u := 0.5 + math.Atan2(dx, -dz)/(2*math.Pi)
+				v := math.Acos(dy/length) / math.Pi
+				for c := 0; c < img.channels; c++ {
+					face.setValue(x, y, c, img.sampleBilinear(u, v, c))
+				}
+			}
+		}
+		faces[i] = face
+	}
+	return faces
+}
+
+// sampleBilinear filters a channel at normalized coordinates, wrapping horizontally and clamping vertically
+func (img *textureImage) sampleBilinear(u, v float64, channel int) float32 {
+	x := u*float64(img.width) - 0.5
+	y := math.Max(0, math.Min(float64(img.height-1), v*float64(img.height)-0.5))
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := float32(x-x0), float32(y-y0)
+
+	column := func(x int) int { return ((x % img.width) + img.width) % img.width }
+	left, right := column(int(x0)), column(int(x0)+1)
+	top := int(y0)
+	bottom := top + 1
+	if bottom >= img.height {
+		bottom = img.height - 1
+	}
+
+	upper := img.value(left, top, channel)*(1-fx) + img.value(right, top, channel)*fx
+	lower := img.value(left, bottom, channel)*(1-fx) + img.value(right, bottom, channel)*fx
+	return upper*(1-fy) + lower*fy
+}
+
+// uploadCubemap fills the given GL cubemap with the six faces and sets its sampler parameters
+func uploadCubemap(texID uint32, faces []*textureImage, sampler samplerSettings) {
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_CUBE_MAP, texID)
+	sampler.apply(gl.TEXTURE_CUBE_MAP)
+	applySwizzle(gl.TEXTURE_CUBE_MAP, faces[0].channels)
+	// Filter across the face edges instead of clamping at them
+	gl.Enable(gl.TEXTURE_CUBE_MAP_SEAMLESS)
+
+	for i, face := range faces {
+		texImage2D(gl.TEXTURE_CUBE_MAP_POSITIVE_X+uint32(i), face)
+	}
+	if sampler.Mipmaps {
+		gl.GenerateMipmap(gl.TEXTURE_CUBE_MAP)
+	}
+}
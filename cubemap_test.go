@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+// newCellImage returns a one channel image of cells, each filled with its index in reading order
+func newCellImage(columns, rows, size int) *textureImage {
+	img := &textureImage{width: columns * size, height: rows * size, channels: 1}
+	img.pixels = make([]uint8, img.width*img.height)
+	for y := 0; y < img.height; y++ {
+		for x := 0; x < img.width; x++ {
+			img.pixels[y*img.width+x] = uint8((y/size)*columns + x/size)
+		}
+	}
+	return img
+}
+
+func TestSplitCubemapCross(t *testing.T) {
+	faces, err := splitCubemap(newCellImage(4, 3, 2))
+	assert.NilError(t, err)
+	assert.Equal(t, len(faces), 6)
+	// +X, -X, +Y, -Y, +Z and -Z of the horizontal cross
+	for i, cell := range []uint8{6, 4, 1, 9, 5, 7} {
+		assert.Equal(t, faces[i].width, 2)
+		assert.DeepEqual(t, faces[i].pixels, []uint8{cell, cell, cell, cell})
+	}
+
+	// The -Z face of the vertical cross is stored upside down
+	img := newCellImage(3, 4, 2)
+	img.pixels[6*6+2] = 99 // top left pixel of the bottom cell
+	faces, err = splitCubemap(img)
+	assert.NilError(t, err)
+	for i, cell := range []uint8{5, 3, 1, 7, 4} {
+		assert.Equal(t, faces[i].pixels[0], cell)
+	}
+	assert.DeepEqual(t, faces[5].pixels, []uint8{10, 10, 10, 99})
+
+	_, err = splitCubemap(newCellImage(3, 3, 2))
+	assert.ErrorContains(t, err, "neither")
+}
+
+func TestEquirectToCubemap(t *testing.T) {
+	// A panorama that is bright above the horizon and dark below it
+	img := &textureImage{width: 16, height: 8, channels: 3, floats: make([]float32, 16*8*3)}
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 16; x++ {
+			for c := 0; c < 3; c++ {
+				img.setValue(x, y, c, 2)
+			}
+		}
+	}
+
+	faces, err := splitCubemap(img)
+	assert.NilError(t, err)
+	assert.Equal(t, faces[0].width, 4)
+	assert.Equal(t, faces[0].format().name, "RGB16F")
+	assert.Equal(t, faces[2].value(1, 1, 0), float32(2))
+	assert.Equal(t, faces[3].value(1, 1, 0), float32(0))
+	// The side faces have the sky in their top rows
+	for _, side := range []int{0, 1, 4, 5} {
+		assert.Equal(t, faces[side].value(0, 0, 1), float32(2))
+		assert.Equal(t, faces[side].value(3, 3, 1), float32(0))
+	}
+}
+
+func TestCubemapFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cubemap")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"posx", "negx", "posy", "negy", "posz", "negz"} {
+		assert.NilError(t, ioutil.WriteFile(filepath.Join(dir, "sky_"+name+".png"), nil, 0644))
+	}
+	files, err := cubemapFiles(filepath.Join(dir, "sky_*.png"))
+	assert.NilError(t, err)
+	assert.Equal(t, len(files), 6)
+	assert.Equal(t, files[3], filepath.Join(dir, "sky_negy.png"))
+
+	_, err = cubemapFiles(filepath.Join(dir, "other_*.png"))
+	assert.ErrorContains(t, err, "no six face files")
+
+	files, err = cubemapFiles("Assets/sky.png")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, files, []string{"Assets/sky.png"})
+
+	// The bundled panorama becomes six square faces
+	faces, _, err := readCubemap("Assets/sky.png")
+	assert.NilError(t, err)
+	assert.Equal(t, len(faces), 6)
+	assert.Equal(t, faces[5].width, faces[5].height)
+}
+
+func TestCubemapField(t *testing.T) {
+	uniforms := getUniforms(`struct Material {
+		samplerCube environment;
+		sampler2D albedo;
+	};`)
+	assert.Equal(t, uniforms[0].uType, uniformTexCube)
+
+	mat := material{}
+	for _, u := range uniforms {
+		mat.fields = append(mat.fields, newMaterialField(u))
+	}
+	env := mat.fields[0].(*matFieldTexture)
+	assert.Assert(t, env.cube)
+	assert.Equal(t, env.savedValue().Type, uniformTexCube)
+	assert.Assert(t, !animatable(env))
+
+	env.tex.id, env.location = 3, 1
+	mat.fields[1].(*matFieldTexture).tex.id = 4
+	mat.rebuildTexBindings()
+	assert.Equal(t, mat.texBindings[0], textureBinding{glTexID: 3, uniformLocation: 1, cube: true})
+	assert.Equal(t, mat.texBindings[1].cube, false)
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/inkyblackness/imgui-go"
+)
+
+// glslTokenKind classifies a highlighted span of GLSL source
+type glslTokenKind int
+
+const (
+	tokenPlain glslTokenKind = iota
+	tokenKeyword
+	tokenType
+	tokenNumber
+	tokenComment
+	tokenPreprocessor
+)
+
+// glslToken is a span of a source line, start and end are byte offsets into the line
+type glslToken struct {
+	start int
+	end   int
+	kind  glslTokenKind
+}
+
+var glslKeywords = map[string]bool{
+	"attribute": true, "const": true, "uniform": true, "varying": true, "layout": true,
+	"centroid": true, "flat": true, "smooth": true, "noperspective": true, "break": true,
+	"continue": true, "do": true, "for": true, "while": true, "switch": true, "case": true,
+	"default": true, "if": true, "else": true, "in": true, "out": true, "inout": true,
+	"true": true, "false": true, "invariant": true, "discard": true, "return": true,
+	"struct": true, "precision": true, "highp": true, "mediump": true, "lowp": true,
+}
+
+var glslTypes = map[string]bool{
+	"void": true, "bool": true, "int": true, "uint": true, "float": true,
+	"vec2": true, "vec3": true, "vec4": true, "bvec2": true, "bvec3": true, "bvec4": true,
+	"ivec2": true, "ivec3": true, "ivec4": true, "uvec2": true, "uvec3": true, "uvec4": true,
+	"mat2": true, "mat3": true, "mat4": true, "mat2x2": true, "mat2x3": true, "mat2x4": true,
+	"mat3x2": true, "mat3x3": true, "mat3x4": true, "mat4x2": true, "mat4x3": true, "mat4x4": true,
+	"sampler1D": true, "sampler2D": true, "sampler3D": true, "samplerCube": true,
+	"sampler2DShadow": true, "samplerCubeShadow": true, "sampler2DArray": true,
+	"isampler2D": true, "usampler2D": true, "sampler2DRect": true, "samplerBuffer": true,
+}
+
+// Highlight colors indexed by glslTokenKind
+var glslTokenColors = []imgui.Vec4{
+	tokenPlain:        {X: 0.9, Y: 0.9, Z: 0.9, W: 1},
+	tokenKeyword:      {X: 0.35, Y: 0.6, Z: 1, W: 1},
+	tokenType:         {X: 0.3, Y: 0.85, Z: 0.75, W: 1},
+	tokenNumber:       {X: 1, Y: 0.65, Z: 0.3, W: 1},
+	tokenComment:      {X: 0.45, Y: 0.7, Z: 0.4, W: 1},
+	tokenPreprocessor: {X: 0.8, Y: 0.5, Z: 0.9, W: 1},
+}
+
+// tokenizeGLSLLine splits a line into highlighted spans covering the whole line.
+// inComment tells if the line starts inside a block comment, the returned bool tells if the next line does.
+func tokenizeGLSLLine(line string, inComment bool) ([]glslToken, bool) {
+	tokens := make([]glslToken, 0)
+	add := func(start int, end int, kind glslTokenKind) {
+		// Merge with the previous span when the kind doesn't change
+		if last := len(tokens) - 1; last >= 0 && tokens[last].kind == kind && tokens[last].end == start {
+			tokens[last].end = end
+			return
+		}
+		tokens = append(tokens, glslToken{start, end, kind})
+	}
+
+	i := 0
+	if inComment {
+		end := strings.Index(line, "*/")
+		if end < 0 {
+			if len(line) > 0 {
+				add(0, len(line), tokenComment)
+			}
+			return tokens, true
+		}
+		add(0, end+2, tokenComment)
+		i = end + 2
+	}
+
+	for i < len(line) {
+		c := line[i]
+		switch {
+		case c == '#' && strings.TrimSpace(line[:i]) == "":
+			add(i, len(line), tokenPreprocessor)
+			i = len(line)
+		case strings.HasPrefix(line[i:], "//"):
+			add(i, len(line), tokenComment)
+			i = len(line)
+		case strings.HasPrefix(line[i:], "/*"):
+			end := strings.Index(line[i+2:], "*/")
+			if end < 0 {
+				add(i, len(line), tokenComment)
+				return tokens, true
+			}
+			add(i, i+2+end+2, tokenComment)
+			i += 2 + end + 2
+		case isDigit(c) || (c == '.' && i+1 < len(line) && isDigit(line[i+1])):
+			start := i
+			for i < len(line) && (isIdentChar(line[i]) || line[i] == '.' ||
+				((line[i] == '+' || line[i] == '-') && (line[i-1] == 'e' || line[i-1] == 'E'))) {
+				i++
+			}
+			add(start, i, tokenNumber)
+		case isIdentChar(c):
+			start := i
+			for i < len(line) && isIdentChar(line[i]) {
+				i++
+			}
+			word := line[start:i]
+			if glslKeywords[word] {
+				add(start, i, tokenKeyword)
+			} else if glslTypes[word] {
+				add(start, i, tokenType)
+			} else {
+				add(start, i, tokenPlain)
+			}
+		default:
+			add(i, i+1, tokenPlain)
+			i++
+		}
+	}
+	return tokens, false
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// editorTab is a source file open in the shader editor
+type editorTab struct {
+	label string
+	// path points at the source path field in the viewer state, so Save As changes what gets compiled
+	path  *string
+	text  string
+	saved string
+}
+
+func (t *editorTab) dirty() bool {
+	return t.text != t.saved
+}
+
+func (t *editorTab) load() error {
+	bytes, err := ioutil.ReadFile(*t.path)
+	if err != nil {
+		return err
+	}
+	t.text = string(bytes)
+	t.saved = t.text
+	return nil
+}
+
+func (t *editorTab) saveAs(path string) error {
+	if err := ioutil.WriteFile(path, []byte(t.text), 0644); err != nil {
+		return err
+	}
+	*t.path = path
+	t.saved = t.text
+	return nil
+}
+
+// shaderEditor edits the source files of the active shader, one tab per file
+type shaderEditor struct {
+	tabs       []editorTab
+	active     int
+	saveAsPath string
+	err        error
+}
+
+// open shows a tab for every non empty path. Tabs with unsaved changes to the same file are kept.
+func (e *shaderEditor) open(labels []string, paths []*string) {
+	tabs := make([]editorTab, 0, len(paths))
+	for i, path := range paths {
+		if *path == "" {
+			continue
+		}
+
+		tab := editorTab{label: labels[i], path: path}
+		for _, old := range e.tabs {
+			if *old.path == *path && old.dirty() {
+				tab.text, tab.saved = old.text, old.saved
+			}
+		}
+		if !tab.dirty() {
+			if err := tab.load(); err != nil {
+				log.Printf("ERROR: " + err.Error())
+				continue
+			}
+		}
+		tabs = append(tabs, tab)
+	}
+
+	e.tabs = tabs
+	if e.active >= len(e.tabs) {
+		e.active = 0
+	}
+}
+
+// save writes the active tab to its file
+func (e *shaderEditor) save() error {
+	if e.active >= len(e.tabs) {
+		return nil
+	}
+	tab := &e.tabs[e.active]
+	return tab.saveAs(*tab.path)
+}
+
+// drawUI draws the editor window content. It returns true when Ctrl+S saved the active tab,
+// so the caller can recompile.
+func (e *shaderEditor) drawUI() bool {
+	if len(e.tabs) == 0 {
+		imgui.Text("Compile a shader to edit its sources")
+		return false
+	}
+
+	saveAndCompile := false
+	// The source area is a child window, so it counts as focus on the editor
+	if imgui.IsWindowFocusedV(imgui.FocusedFlagsRootAndChildWindows) && (imgui.IsKeyDown(int(glfw.KeyLeftControl)) || imgui.IsKeyDown(int(glfw.KeyRightControl))) &&
+		imgui.IsKeyPressed(int(glfw.KeyS)) {
+		e.err = e.save()
+		saveAndCompile = e.err == nil
+	}
+
+	if imgui.Button("Save") {
+		e.err = e.save()
+	}
+	imgui.SameLine()
+	if imgui.Button("Save As") && e.saveAsPath != "" {
+		e.err = e.tabs[e.active].saveAs(e.saveAsPath)
+	}
+	imgui.SameLine()
+	imgui.InputText("##saveAsPath", &e.saveAsPath)
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Path the active tab is written to by Save As. Ctrl+S saves and recompiles")
+	}
+	if e.err != nil {
+		imgui.Text("ERROR: " + e.err.Error())
+	}
+
+	if imgui.BeginTabBar("##editorTabs") {
+		for i := range e.tabs {
+			tab := &e.tabs[i]
+			label := fmt.Sprintf("%s %s", tab.label, filepath.Base(*tab.path))
+			if tab.dirty() {
+				label += " *"
+			}
+			// The ### suffix keeps the tab ID stable while the dirty marker changes
+			if imgui.BeginTabItem(label + "###editorTab" + strconv.Itoa(i)) {
+				e.active = i
+				drawSourceText(tab)
+				imgui.EndTabItem()
+			}
+		}
+		imgui.EndTabBar()
+	}
+	return saveAndCompile
+}
+
+// drawSourceText draws a multiline text area with line numbers and GLSL highlighting.
+// The text widget is sized to fit every line inside a scrolling child window and draws its
+// text transparent, the highlighted text is drawn over it with the window draw list.
+func drawSourceText(tab *editorTab) {
+	imgui.BeginChildV("##sourceScroll", imgui.Vec2{X: -1, Y: -1}, true, imgui.WindowFlagsHorizontalScrollbar)
+
+	lines := strings.Split(tab.text, "\n")
+	lineHeight := imgui.TextLineHeight()
+	gutterWidth := imgui.CalcTextSize(strconv.Itoa(len(lines))+"  ", false, 0).X
+
+	origin := imgui.CursorScreenPos()
+	textOrigin := imgui.Vec2{X: origin.X + gutterWidth, Y: origin.Y}
+
+	longest := ""
+	for _, line := range lines {
+		if len(line) > len(longest) {
+			longest = line
+		}
+	}
+	size := imgui.Vec2{
+		X: imgui.CalcTextSize(longest, false, 0).X + 2*lineHeight,
+		Y: float32(len(lines)+2) * lineHeight}
+	if avail := imgui.ContentRegionAvail(); size.X < avail.X-gutterWidth {
+		size.X = avail.X - gutterWidth
+	}
+
+	imgui.SetCursorScreenPos(textOrigin)
+	imgui.PushStyleVarVec2(imgui.StyleVarFramePadding, imgui.Vec2{})
+	imgui.PushStyleColor(imgui.StyleColorText, imgui.Vec4{})
+	imgui.InputTextMultilineV("##source", &tab.text, size, imgui.InputTextFlagsAllowTabInput, nil)
+	imgui.PopStyleColor()
+	imgui.PopStyleVar()
+
+	drawList := imgui.WindowDrawList()
+	numberColor := imgui.PackedColorFromVec4(imgui.Vec4{X: 0.5, Y: 0.5, Z: 0.5, W: 1})
+	colors := make([]imgui.PackedColor, len(glslTokenColors))
+	for i, color := range glslTokenColors {
+		colors[i] = imgui.PackedColorFromVec4(color)
+	}
+
+	// Re-split since the widget may have edited the text this frame
+	inComment := false
+	for i, line := range strings.Split(tab.text, "\n") {
+		y := origin.Y + float32(i)*lineHeight
+		drawList.AddText(imgui.Vec2{X: origin.X, Y: y}, numberColor, strconv.Itoa(i+1))
+
+		var tokens []glslToken
+		tokens, inComment = tokenizeGLSLLine(line, inComment)
+		for _, token := range tokens {
+			x := textOrigin.X + imgui.CalcTextSize(line[:token.start], false, 0).X
+			drawList.AddText(imgui.Vec2{X: x, Y: y}, colors[token.kind], line[token.start:token.end])
+		}
+	}
+
+	imgui.EndChild()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestTokenizeGLSLLine(t *testing.T) {
+	line := "uniform vec3 color; // tint"
+	tokens, inComment := tokenizeGLSLLine(line, false)
+	assert.Assert(t, !inComment)
+
+	expected := []struct {
+		text string
+		kind glslTokenKind
+	}{
+		{"uniform", tokenKeyword},
+		{" ", tokenPlain},
+		{"vec3", tokenType},
+		{" color; ", tokenPlain},
+		{"// tint", tokenComment},
+	}
+	assert.Equal(t, len(tokens), len(expected))
+	for i, token := range tokens {
+		assert.Equal(t, line[token.start:token.end], expected[i].text)
+		assert.Equal(t, token.kind, expected[i].kind)
+	}
+
+	tokens, _ = tokenizeGLSLLine("  #version 330", false)
+	assert.Equal(t, len(tokens), 2)
+	assert.Equal(t, tokens[1].kind, tokenPreprocessor)
+
+	line = "x = 1.5e-3 + .5;"
+	tokens, _ = tokenizeGLSLLine(line, false)
+	assert.Equal(t, line[tokens[1].start:tokens[1].end], "1.5e-3")
+	assert.Equal(t, tokens[1].kind, tokenNumber)
+	assert.Equal(t, line[tokens[3].start:tokens[3].end], ".5")
+	assert.Equal(t, tokens[3].kind, tokenNumber)
+}
+
+func TestTokenizeGLSLBlockComment(t *testing.T) {
+	tokens, inComment := tokenizeGLSLLine("float a; /* start", false)
+	assert.Assert(t, inComment)
+	assert.Equal(t, tokens[len(tokens)-1].kind, tokenComment)
+
+	tokens, inComment = tokenizeGLSLLine("still comment", true)
+	assert.Assert(t, inComment)
+	assert.Equal(t, len(tokens), 1)
+	assert.Equal(t, tokens[0].kind, tokenComment)
+
+	line := "end */ return;"
+	tokens, inComment = tokenizeGLSLLine(line, true)
+	assert.Assert(t, !inComment)
+	assert.Equal(t, line[tokens[0].start:tokens[0].end], "end */")
+	assert.Equal(t, line[tokens[2].start:tokens[2].end], "return")
+	assert.Equal(t, tokens[2].kind, tokenKeyword)
+}
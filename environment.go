@@ -1,65 +1,132 @@
 package main
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/go-gl/gl/v3.2-core/gl"
 )
 
-// TODO: This should clearly use a Uniform Buffer Object.
-
 type globalRenderProperties struct {
 	LightDir   [3]float32
 	LightColor [3]float32
 	CameraPos  [3]float32
 	Time       float32
+	DeltaTime  float32
+	FrameIndex int32
+	Resolution [2]float32
+	Mouse      [2]float32
+	MouseDown  bool
+	MouseClick [2]float32
+	MouseDrag  [2]float32
+	CameraNear float32
+	CameraFar  float32
 }
 
 // GlobalRenderProps holds the global render properties for all renderers
-var GlobalRenderProps globalRenderProperties = globalRenderProperties{LightDir: [3]float32{0.5, 1.2, 1.5}, LightColor: [3]float32{1, 1, 1}}
+var GlobalRenderProps globalRenderProperties = globalRenderProperties{
+	LightDir:   [3]float32{0.5, 1.2, 1.5},
+	LightColor: [3]float32{1, 1, 1},
+	CameraNear: 0.1,
+	CameraFar:  10.0,
+}
 
-// ApplyGlobalRenderProperties applies all global render properties to a given shader
-func ApplyGlobalRenderProperties(program uint32) {
-	gl.UseProgram(program)
+// Shaders can read the per frame properties from the Globals uniform block instead of separate uniforms.
+// The block is backed by a single uniform buffer that is updated once per frame.
+const (
+	globalsBlockName    string = "Globals"
+	globalsBindingPoint uint32 = 0
+)
+
+// globalsBlockMembers lists the built-in uniforms in the Globals block, in declaration order
+var globalsBlockMembers = []string{
+	timeName,
+	deltaTimeName,
+	frameIndexName,
+	resolutionName,
+	mouseName,
+	lightDirName,
+	lightColorName,
+	camWorldPosName,
+	cameraNearName,
+	cameraFarName,
+}
+
+var globalsBuffer uint32
+var globalsData std140Buffer
 
-	// Apply LightDir
-	lightDirUniform := gl.GetUniformLocation(program, gl.Str(lightDirName+"\x00"))
-	gl.Uniform3f(lightDirUniform, GlobalRenderProps.LightDir[0], GlobalRenderProps.LightDir[1], GlobalRenderProps.LightDir[2])
+// globalsBlockSource returns the GLSL declaration of the Globals block matching the packed buffer
+func globalsBlockSource() string {
+	var source strings.Builder
+	source.WriteString("layout(std140) uniform " + globalsBlockName + " {\n")
+	for _, name := range globalsBlockMembers {
+		builtin, _ := findBuiltinUniform(name)
+		source.WriteString(fmt.Sprintf("    %s %s;\n", builtin.uType, builtin.name))
+	}
+	source.WriteString("};\n")
+	return source.String()
+}
+
+// packGlobals packs the current values of the Globals block members with the std140 layout
+func packGlobals(buffer *std140Buffer) []byte {
+	buffer.reset()
+	for _, name := range globalsBlockMembers {
+		builtin, _ := findBuiltinUniform(name)
+		buffer.addValue(builtin.provide(nil))
+	}
+	return buffer.bytes()
+}
 
-	// Apply LightColor
-	lightColorUniform := gl.GetUniformLocation(program, gl.Str(lightColorName+"\x00"))
-	gl.Uniform3f(lightColorUniform, GlobalRenderProps.LightColor[0], GlobalRenderProps.LightColor[1], GlobalRenderProps.LightColor[2])
+// UpdateGlobalsBuffer uploads the global render properties to the Globals uniform buffer. Call once per frame.
+func UpdateGlobalsBuffer() {
+	data := packGlobals(&globalsData)
 
-	// Apply WorldSpaceCameraPos
-	cameraPositionUniform := gl.GetUniformLocation(program, gl.Str(camWorldPosName+"\x00"))
-	gl.Uniform3f(cameraPositionUniform, GlobalRenderProps.CameraPos[0], GlobalRenderProps.CameraPos[1], GlobalRenderProps.CameraPos[2])
+	if globalsBuffer == 0 {
+		gl.GenBuffers(1, &globalsBuffer)
+		gl.BindBuffer(gl.UNIFORM_BUFFER, globalsBuffer)
+		gl.BufferData(gl.UNIFORM_BUFFER, len(data), nil, gl.DYNAMIC_DRAW)
+		gl.BindBufferBase(gl.UNIFORM_BUFFER, globalsBindingPoint, globalsBuffer)
+	}
+
+	gl.BindBuffer(gl.UNIFORM_BUFFER, globalsBuffer)
+	gl.BufferSubData(gl.UNIFORM_BUFFER, 0, len(data), gl.Ptr(data))
+	gl.BindBuffer(gl.UNIFORM_BUFFER, 0)
+}
 
-	// Apply Time
-	timeUniform := gl.GetUniformLocation(program, gl.Str(timeName+"\x00"))
-	gl.Uniform1f(timeUniform, GlobalRenderProps.Time)
+// bindGlobalsBlock connects the Globals block of a program, if it declares one, to the shared uniform buffer
+func bindGlobalsBlock(program uint32) {
+	blockIndex := gl.GetUniformBlockIndex(program, gl.Str(globalsBlockName+"\x00"))
+	if blockIndex != gl.INVALID_INDEX {
+		gl.UniformBlockBinding(program, blockIndex, globalsBindingPoint)
+	}
+}
+
+// ApplyGlobalRenderProperties applies all per frame built-in uniforms to a given shader.
+// Members of the Globals block have no uniform location and are skipped.
+func ApplyGlobalRenderProperties(program uint32) {
+	gl.UseProgram(program)
+	applyBuiltinUniforms(program, scopeFrame, nil)
 }
 
 // ApplyLightDir applies the light position to a given shader
 func ApplyLightDir(program uint32) {
 	gl.UseProgram(program)
-	lightDirUniform := gl.GetUniformLocation(program, gl.Str(lightDirName+"\x00"))
-	gl.Uniform3f(lightDirUniform, GlobalRenderProps.LightDir[0], GlobalRenderProps.LightDir[1], GlobalRenderProps.LightDir[2])
+	applyBuiltinUniform(program, lightDirName, nil)
 }
 
 // ApplyLightColor applies the light position to a given shader
 func ApplyLightColor(program uint32) {
 	gl.UseProgram(program)
-	lightColorUniform := gl.GetUniformLocation(program, gl.Str(lightColorName+"\x00"))
-	gl.Uniform3f(lightColorUniform, GlobalRenderProps.LightColor[0], GlobalRenderProps.LightColor[1], GlobalRenderProps.LightColor[2])
+	applyBuiltinUniform(program, lightColorName, nil)
 }
 
 // ApplyCameraPosition applies the camera position to a given shader
 func ApplyCameraPosition(program uint32) {
 	gl.UseProgram(program)
-	cameraPositionUniform := gl.GetUniformLocation(program, gl.Str(camWorldPosName+"\x00"))
-	gl.Uniform3f(cameraPositionUniform, GlobalRenderProps.CameraPos[0], GlobalRenderProps.CameraPos[1], GlobalRenderProps.CameraPos[2])
+	applyBuiltinUniform(program, camWorldPosName, nil)
 }
 
 // ApplyTime applies the time variable to a given shader
 func ApplyTime(program uint32) {
-	timeUniform := gl.GetUniformLocation(program, gl.Str(timeName+"\x00"))
-	gl.Uniform1f(timeUniform, GlobalRenderProps.Time)
+	applyBuiltinUniform(program, timeName, nil)
 }
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// exprEnv holds the variables an expression can read, e.g. "time" or "mouse.x"
+type exprEnv map[string]float64
+
+// exprNode is a parsed expression. Evaluating it can't fail: unknown names are rejected by the parser.
+type exprNode interface {
+	eval(env exprEnv) float64
+}
+
+type exprNumber float64
+
+type exprVariable string
+
+type exprUnary struct {
+	operand exprNode
+}
+
+type exprBinary struct {
+	op          byte
+	left, right exprNode
+}
+
+type exprCall struct {
+	function exprFunction
+	args     []exprNode
+}
+
+func (n exprNumber) eval(env exprEnv) float64   { return float64(n) }
+func (n exprVariable) eval(env exprEnv) float64 { return env[string(n)] }
+func (n exprUnary) eval(env exprEnv) float64    { return -n.operand.eval(env) }
+
+func (n exprBinary) eval(env exprEnv) float64 {
+	left, right := n.left.eval(env), n.right.eval(env)
+	switch n.op {
+	case '+':
+		return left + right
+	case '-':
+		return left - right
+	case '*':
+		return left * right
+	case '/':
+		return left / right
+	case '%':
+		return glslMod(left, right)
+	default:
+		return math.Pow(left, right)
+	}
+}
+
+func (n exprCall) eval(env exprEnv) float64 {
+	args := make([]float64, len(n.args))
+	for i, arg := range n.args {
+		args[i] = arg.eval(env)
+	}
+	return n.function.call(args)
+}
+
+// exprFunction is a math function callable from expressions
+type exprFunction struct {
+	arity int
+	call  func(args []float64) float64
+}
+
+func unaryFunction(f func(float64) float64) exprFunction {
+	return exprFunction{1, func(args []float64) float64 { return f(args[0]) }}
+}
+
+func binaryFunction(f func(float64, float64) float64) exprFunction {
+	return exprFunction{2, func(args []float64) float64 { return f(args[0], args[1]) }}
+}
+
+// glslMod is mod as GLSL defines it, so the result has the sign of y
+func glslMod(x, y float64) float64 {
+	return x - y*math.Floor(x/y)
+}
+
+func clamp(x, low, high float64) float64 {
+	return math.Min(math.Max(x, low), high)
+}
+
+// exprFunctions follow the GLSL built-in functions of the same name
+var exprFunctions = map[string]exprFunction{
+	"sin":   unaryFunction(math.Sin),
+	"cos":   unaryFunction(math.Cos),
+	"tan":   unaryFunction(math.Tan),
+	"asin":  unaryFunction(math.Asin),
+	"acos":  unaryFunction(math.Acos),
+	"atan":  unaryFunction(math.Atan),
+	"sqrt":  unaryFunction(math.Sqrt),
+	"abs":   unaryFunction(math.Abs),
+	"floor": unaryFunction(math.Floor),
+	"ceil":  unaryFunction(math.Ceil),
+	"exp":   unaryFunction(math.Exp),
+	"log":   unaryFunction(math.Log),
+	"fract": unaryFunction(func(x float64) float64 { return x - math.Floor(x) }),
+	"sign": unaryFunction(func(x float64) float64 {
+		switch {
+		case x > 0:
+			return 1
+		case x < 0:
+			return -1
+		}
+		return 0
+	}),
+	"atan2": binaryFunction(math.Atan2),
+	"pow":   binaryFunction(math.Pow),
+	"mod":   binaryFunction(glslMod),
+	"min":   binaryFunction(math.Min),
+	"max":   binaryFunction(math.Max),
+	"step": binaryFunction(func(edge, x float64) float64 {
+		if x < edge {
+			return 0
+		}
+		return 1
+	}),
+	"clamp": {3, func(args []float64) float64 { return clamp(args[0], args[1], args[2]) }},
+	"mix":   {3, func(args []float64) float64 { return args[0] + (args[1]-args[0])*args[2] }},
+	"smoothstep": {3, func(args []float64) float64 {
+		t := clamp((args[2]-args[0])/(args[1]-args[0]), 0, 1)
+		return t * t * (3 - 2*t)
+	}},
+}
+
+// exprConstants can be used like variables
+var exprConstants = map[string]float64{
+	"pi": math.Pi,
+	"e":  math.E,
+}
+
+// maxExprDepth limits the nesting of parentheses and unary minus, so a hostile expression can't exhaust the stack
+const maxExprDepth = 64
+
+// builtinExprEnv returns the per frame built-in uniforms as expression variables.
+// Vectors are split into their components, e.g. "resolution.x" and "resolution.y".
+func builtinExprEnv() exprEnv {
+	env := make(exprEnv)
+	for _, builtin := range builtinUniforms {
+		if builtin.scope != scopeFrame {
+			continue
+		}
+
+		var components []float32
+		switch value := builtin.provide(nil).(type) {
+		case float32:
+			env[builtin.name] = float64(value)
+		case int32:
+			env[builtin.name] = float64(value)
+		case mgl32.Vec2:
+			components = value[:]
+		case mgl32.Vec3:
+			components = value[:]
+		case mgl32.Vec4:
+			components = value[:]
+		}
+		for i, component := range components {
+			env[builtin.name+"."+"xyzw"[i:i+1]] = float64(component)
+		}
+	}
+	return env
+}
+
+// exprParser is a recursive descent parser over the characters of an expression
+type exprParser struct {
+	source string
+	pos    int
+	depth  int
+	env    exprEnv
+}
+
+// parseExpressionList parses comma separated expressions, one per component of a vector field.
+// Variables must exist in env.
+func parseExpressionList(source string, env exprEnv) ([]exprNode, error) {
+	p := &exprParser{source: source, env: env}
+	nodes := make([]exprNode, 0)
+	for {
+		node, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+
+		p.skipSpace()
+		if p.pos == len(p.source) {
+			return nodes, nil
+		}
+		if !p.consume(',') {
+			return nil, p.errorf("unexpected %q", p.source[p.pos])
+		}
+	}
+}
+
+func (p *exprParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("column %d: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.source) && (p.source[p.pos] == ' ' || p.source[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// consume skips the given character if it comes next
+func (p *exprParser) consume(c byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.source) && p.source[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// parseSum parses terms joined by + and -
+func (p *exprParser) parseSum() (exprNode, error) {
+	left, err := p.parseProduct()
+	for err == nil {
+		var op byte
+		switch {
+		case p.consume('+'):
+			op = '+'
+		case p.consume('-'):
+			op = '-'
+		default:
+			return left, nil
+		}
+		var right exprNode
+		right, err = p.parseProduct()
+		left = exprBinary{op, left, right}
+	}
+	return nil, err
+}
+
+// parseProduct parses factors joined by *, / and %
+func (p *exprParser) parseProduct() (exprNode, error) {
+	left, err := p.parseUnary()
+	for err == nil {
+		var op byte
+		switch {
+		case p.consume('*'):
+			op = '*'
+		case p.consume('/'):
+			op = '/'
+		case p.consume('%'):
+			op = '%'
+		default:
+			return left, nil
+		}
+		var right exprNode
+		right, err = p.parseUnary()
+		left = exprBinary{op, left, right}
+	}
+	return nil, err
+}
+
+// parseUnary parses a negation or a power
+func (p *exprParser) parseUnary() (exprNode, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxExprDepth {
+		return nil, p.errorf("expression nested too deeply")
+	}
+
+	if p.consume('-') {
+		operand, err := p.parseUnary()
+		return exprUnary{operand}, err
+	}
+	if p.consume('+') {
+		return p.parseUnary()
+	}
+	return p.parsePower()
+}
+
+// parsePower parses a primary raised to a power. ^ is right associative and binds tighter than negation.
+func (p *exprParser) parsePower() (exprNode, error) {
+	base, err := p.parsePrimary()
+	if err != nil || !p.consume('^') {
+		return base, err
+	}
+	exponent, err := p.parseUnary()
+	return exprBinary{'^', base, exponent}, err
+}
+
+// parsePrimary parses a number, a variable, a function call or a parenthesized expression
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	p.skipSpace()
+	if p.pos == len(p.source) {
+		return nil, p.errorf("unexpected end of expression")
+	}
+
+	c := rune(p.source[p.pos])
+	switch {
+	case c == '(':
+		p.pos++
+		node, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(')') {
+			return nil, p.errorf("missing )")
+		}
+		return node, nil
+	case unicode.IsDigit(c) || c == '.':
+		return p.parseNumber()
+	case unicode.IsLetter(c) || c == '_':
+		return p.parseName()
+	}
+	return nil, p.errorf("unexpected %q", p.source[p.pos])
+}
+
+func (p *exprParser) parseNumber() (exprNode, error) {
+	start := p.pos
+	for p.pos < len(p.source) && (isDigit(p.source[p.pos]) || p.source[p.pos] == '.') {
+		p.pos++
+	}
+	// An exponent such as 1e-05, as long as it isn't the constant e
+	if exponent := p.pos; exponent < len(p.source) && (p.source[exponent] == 'e' || p.source[exponent] == 'E') {
+		exponent++
+		if exponent < len(p.source) && (p.source[exponent] == '+' || p.source[exponent] == '-') {
+			exponent++
+		}
+		if exponent < len(p.source) && isDigit(p.source[exponent]) {
+			for p.pos = exponent; p.pos < len(p.source) && isDigit(p.source[p.pos]); p.pos++ {
+			}
+		}
+	}
+	text := p.source[start:p.pos]
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf("bad number %q", text)
+	}
+	return exprNumber(value), nil
+}
+
+// parseName parses a variable such as "time" or "mouse.x", a constant or a function call
+func (p *exprParser) parseName() (exprNode, error) {
+	start := p.pos
+	for p.pos < len(p.source) {
+		c := rune(p.source[p.pos])
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '_' && c != '.' {
+			break
+		}
+		p.pos++
+	}
+	name := p.source[start:p.pos]
+
+	if p.consume('(') {
+		function, ok := exprFunctions[name]
+		if !ok {
+			p.pos = start
+			return nil, p.errorf("unknown function %q", name)
+		}
+		return p.parseCall(name, function)
+	}
+
+	if value, ok := exprConstants[name]; ok {
+		return exprNumber(value), nil
+	}
+	if _, ok := p.env[name]; !ok {
+		p.pos = start
+		return nil, p.errorf("unknown variable %q", name)
+	}
+	return exprVariable(name), nil
+}
+
+// parseCall parses the arguments of a function call after the opening parenthesis
+func (p *exprParser) parseCall(name string, function exprFunction) (exprNode, error) {
+	args := make([]exprNode, 0, function.arity)
+	for !p.consume(')') {
+		if len(args) > 0 && !p.consume(',') {
+			return nil, p.errorf("missing ) after the arguments of %s", name)
+		}
+		arg, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	if len(args) != function.arity {
+		return nil, p.errorf("%s takes %d arguments, not %d", name, function.arity, len(args))
+	}
+	return exprCall{function, args}, nil
+}
+
+// fieldExpression drives the value of a material field
+type fieldExpression struct {
+	source string
+	nodes  []exprNode
+	// edit is the text in the editor, which replaces the source once it parses
+	edit string
+	err  error
+}
+
+// newFieldExpression parses the expression of a field with the given number of components.
+// A single expression sets every component.
+func newFieldExpression(source string, components int, env exprEnv) (*fieldExpression, error) {
+	if strings.TrimSpace(source) == "" {
+		return nil, fmt.Errorf("the expression is empty")
+	}
+	nodes, err := parseExpressionList(source, env)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) != 1 && len(nodes) != components {
+		return nil, fmt.Errorf("expected 1 or %d values, got %d", components, len(nodes))
+	}
+	return &fieldExpression{source: source, nodes: nodes, edit: source}, nil
+}
+
+// eval returns the value of every component
+func (e *fieldExpression) eval(components int, env exprEnv) []float32 {
+	value := make([]float32, components)
+	for i := range value {
+		node := e.nodes[0]
+		if len(e.nodes) > 1 {
+			node = e.nodes[i]
+		}
+		// Division by zero and the like would send NaN or infinity to the shader and break saving
+		value[i] = float32(node.eval(env))
+		if math.IsNaN(float64(value[i])) || math.IsInf(float64(value[i]), 0) {
+			value[i] = 0
+		}
+	}
+	return value
+}
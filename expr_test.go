@@ -0,0 +1,136 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func evalExpression(t *testing.T, source string, env exprEnv) float64 {
+	t.Helper()
+	nodes, err := parseExpressionList(source, env)
+	assert.NilError(t, err, source)
+	assert.Equal(t, len(nodes), 1, source)
+	return nodes[0].eval(env)
+}
+
+func TestExpressionEval(t *testing.T) {
+	env := exprEnv{"time": 0.5, "mouse.x": 200, "resolution.x": 800}
+	tests := []struct {
+		source string
+		want   float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"8 / 2 / 2", 2},
+		{"-2 ^ 2", -4},
+		{"2 ^ 3 ^ 2", 512},
+		{"-(3 - 5)", 2},
+		{"7 % 3", 1},
+		{"-1 % 3", 2},
+		{"mouse.x / resolution.x", 0.25},
+		{"0.5 + 0.5*sin(time*2)", 0.5 + 0.5*math.Sin(1)},
+		{"  clamp(time * 4, 0, 1) ", 1},
+		{"mix(10, 20, time)", 15},
+		{"smoothstep(0, 1, time)", 0.5},
+		{"step(0.6, time) + fract(2.25) + floor(-0.5)", -0.75},
+		{"max(min(3, 4), atan2(0, 1)) + pow(2, 4) + mod(-1, 4)", 22},
+		{"abs(-2) + sqrt(16) + sign(-3)", 5},
+		{"cos(pi)", -1},
+		{".5 + 1.", 1.5},
+		{"1e-05 * 2e5 + 2.5E+1", 27},
+		{"2*e", 2 * math.E},
+	}
+	for _, test := range tests {
+		got := evalExpression(t, test.source, env)
+		assert.Assert(t, math.Abs(got-test.want) < 1e-9, "%s = %v, want %v", test.source, got, test.want)
+	}
+}
+
+func TestExpressionErrors(t *testing.T) {
+	env := exprEnv{"time": 0}
+	tests := []struct {
+		source string
+		err    string
+	}{
+		{"", "unexpected end of expression"},
+		{"1 +", "column 4: unexpected end of expression"},
+		{"(1 + 2", "missing )"},
+		{"1 2", "column 3: unexpected '2'"},
+		{"speed * 2", "column 1: unknown variable \"speed\""},
+		{"noise(time)", "unknown function \"noise\""},
+		{"clamp(time, 1)", "clamp takes 3 arguments, not 2"},
+		{"sin(time 2)", "missing ) after the arguments of sin"},
+		{"1..2", "bad number \"1..2\""},
+		{"time $ 2", "unexpected '$'"},
+		{strings.Repeat("(", 100) + "1" + strings.Repeat(")", 100), "nested too deeply"},
+		{strings.Repeat("-", 100) + "1", "nested too deeply"},
+	}
+	for _, test := range tests {
+		_, err := parseExpressionList(test.source, env)
+		assert.ErrorContains(t, err, test.err, test.source)
+	}
+}
+
+func TestFieldExpression(t *testing.T) {
+	env := exprEnv{"time": 2}
+
+	// One expression sets every component, or there is one per component
+	expression, err := newFieldExpression("time / 4", 3, env)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, expression.eval(3, env), []float32{0.5, 0.5, 0.5})
+
+	expression, err = newFieldExpression("time, 0, -time", 3, env)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, expression.eval(3, env), []float32{2, 0, -2})
+
+	// Division by zero and other non-finite results become 0
+	expression, err = newFieldExpression("1 / (time - 2), sqrt(-1), log(0)", 3, env)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, expression.eval(3, env), []float32{0, 0, 0})
+
+	_, err = newFieldExpression("1, 2", 3, env)
+	assert.ErrorContains(t, err, "expected 1 or 3 values, got 2")
+	_, err = newFieldExpression("  ", 1, env)
+	assert.ErrorContains(t, err, "empty")
+}
+
+func TestBuiltinExprEnv(t *testing.T) {
+	props := GlobalRenderProps
+	defer func() { GlobalRenderProps = props }()
+	GlobalRenderProps.Time = 3
+	GlobalRenderProps.Resolution = [2]float32{640, 480}
+
+	env := builtinExprEnv()
+	assert.Equal(t, env["time"], float64(3))
+	assert.Equal(t, env["resolution.y"], float64(480))
+	_, ok := env["lightDir.z"]
+	assert.Assert(t, ok)
+	_, ok = env["modelMatrix"]
+	assert.Assert(t, !ok)
+}
+
+func TestMaterialExpressions(t *testing.T) {
+	env := exprEnv{"time": 1}
+	mat := material{fields: []materialField{
+		&matFieldFloat{name: "pulse"},
+		&matFieldVec2{name: "offset"},
+		&matFieldTexture{name: "albedo"},
+	}}
+
+	assert.NilError(t, mat.setExpression("pulse", "time * 2", env))
+	assert.NilError(t, mat.setExpression("offset", "time, -time", env))
+	assert.ErrorContains(t, mat.setExpression("albedo", "1", env), "can't be driven")
+	assert.ErrorContains(t, mat.setExpression("missing", "1", env), "doesn't exist")
+
+	assert.Equal(t, mat.evaluateExpressions(env), true)
+	assert.Equal(t, mat.fields[0].(*matFieldFloat).value, float32(2))
+	assert.DeepEqual(t, mat.fields[1].savedValue().Value, []float32{1, -1})
+
+	file := mat.file()
+	assert.Equal(t, file.Fields[0].Expression, "time * 2")
+	assert.Equal(t, file.Fields[2].Expression, "")
+}
@@ -0,0 +1,475 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// isRadianceHDR tells if the data starts with the signature of a Radiance .hdr file
+func isRadianceHDR(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("#?RADIANCE")) || bytes.HasPrefix(data, []byte("#?RGBE"))
+}
+
+// maxHDRPixels keeps a corrupt resolution or data window from allocating gigabytes
+const maxHDRPixels = 1 << 26
+
+// decodeRadianceHDR decodes a Radiance RGBE image to three float channels. Flat and run length
+// encoded scanlines are supported, in the standard -Y +X orientation.
+func decodeRadianceHDR(data []byte) (*textureImage, error) {
+	// The header lines end with an empty line, followed by the resolution line
+	pos := 0
+	readLine := func() (string, bool) {
+		end := bytes.IndexByte(data[pos:], '\n')
+		if end < 0 {
+			return "", false
+		}
+		line := string(data[pos : pos+end])
+		pos += end + 1
+		return strings.TrimSpace(line), true
+	}
+	for {
+		line, ok := readLine()
+		if !ok {
+			return nil, fmt.Errorf("hdr: the header is truncated")
+		}
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(line, "FORMAT=") && line != "FORMAT=32-bit_rle_rgbe" {
+			return nil, fmt.Errorf("hdr: %s is not supported", line)
+		}
+	}
+
+	resolution, ok := readLine()
+	fields := strings.Fields(resolution)
+	if !ok || len(fields) != 4 || fields[0] != "-Y" || fields[2] != "+X" {
+		return nil, fmt.Errorf("hdr: resolution %q is not supported", resolution)
+	}
+	height, errY := strconv.Atoi(fields[1])
+	width, errX := strconv.Atoi(fields[3])
+	if errY != nil || errX != nil || width <= 0 || height <= 0 || height > maxHDRPixels/width {
+		return nil, fmt.Errorf("hdr: bad resolution %q", resolution)
+	}
+
+	floats := make([]float32, 0, width*height*3)
+	scanline := make([]byte, width*4)
+	for y := 0; y < height; y++ {
+		var err error
+		if pos, err = readRGBEScanline(data, pos, scanline); err != nil {
+			return nil, fmt.Errorf("hdr: line %d: %v", y, err)
+		}
+		for x := 0; x < width; x++ {
+			r, g, b := rgbeToFloat(scanline[x*4 : x*4+4])
+			floats = append(floats, r, g, b)
+		}
+	}
+	return newFloatTextureImage(width, height, 3, floats), nil
+}
+
+// readRGBEScanline reads one scanline of RGBE pixels into line and returns the position after it
+func readRGBEScanline(data []byte, pos int, line []byte) (int, error) {
+	width := len(line) / 4
+	if pos+4 > len(data) {
+		return pos, fmt.Errorf("truncated")
+	}
+
+	// Run length encoded lines start with 2, 2 and the width. Each channel is encoded separately.
+	if width < 8 || width > 0x7FFF || data[pos] != 2 || data[pos+1] != 2 || data[pos+2]&0x80 != 0 {
+		return readFlatRGBEScanline(data, pos, line)
+	}
+	if int(data[pos+2])<<8|int(data[pos+3]) != width {
+		return pos, fmt.Errorf("the encoded width doesn't match")
+	}
+	pos += 4
+
+	for channel := 0; channel < 4; channel++ {
+		for x := 0; x < width; {
+			if pos >= len(data) {
+				return pos, fmt.Errorf("truncated")
+			}
+			count := int(data[pos])
+			pos++
+			if count > 128 {
+				// A run of the same value
+				count -= 128
+				if x+count > width || pos >= len(data) {
+					return pos, fmt.Errorf("bad run length")
+				}
+				for i := 0; i < count; i++ {
+					line[(x+i)*4+channel] = data[pos]
+				}
+				pos++
+			} else {
+				if count == 0 || x+count > width || pos+count > len(data) {
+					return pos, fmt.Errorf("bad run length")
+				}
+				for i := 0; i < count; i++ {
+					line[(x+i)*4+channel] = data[pos+i]
+				}
+				pos += count
+			}
+			x += count
+		}
+	}
+	return pos, nil
+}
+
+// readFlatRGBEScanline reads uncompressed pixels, where a pixel of 1, 1, 1 repeats the previous one
+func readFlatRGBEScanline(data []byte, pos int, line []byte) (int, error) {
+	width := len(line) / 4
+	shift := uint(0)
+	for x := 0; x < width; {
+		if pos+4 > len(data) {
+			return pos, fmt.Errorf("truncated")
+		}
+		pixel := data[pos : pos+4]
+		pos += 4
+
+		if pixel[0] == 1 && pixel[1] == 1 && pixel[2] == 1 {
+			if x == 0 {
+				return pos, fmt.Errorf("a run without a previous pixel")
+			}
+			count := int(pixel[3]) << shift
+			if x+count > width {
+				return pos, fmt.Errorf("bad run length")
+			}
+			for i := 0; i < count; i++ {
+				copy(line[(x+i)*4:], line[(x-1)*4:x*4])
+			}
+			x += count
+			shift += 8
+			continue
+		}
+		copy(line[x*4:], pixel)
+		x++
+		shift = 0
+	}
+	return pos, nil
+}
+
+// rgbeToFloat decodes a pixel with a shared exponent
+func rgbeToFloat(rgbe []byte) (float32, float32, float32) {
+	if rgbe[3] == 0 {
+		return 0, 0, 0
+	}
+	scale := float32(math.Ldexp(1, int(rgbe[3])-(128+8)))
+	return float32(rgbe[0]) * scale, float32(rgbe[1]) * scale, float32(rgbe[2]) * scale
+}
+
+// OpenEXR pixel types and compression methods
+const (
+	exrUint  = 0
+	exrHalf  = 1
+	exrFloat = 2
+
+	exrNoCompression   = 0
+	exrRLECompression  = 1
+	exrZIPSCompression = 2
+	exrZIPCompression  = 3
+)
+
+// The version flags of tiled, deep and multi-part files
+const exrUnsupportedFlags = 0x200 | 0x800 | 0x1000
+
+// isOpenEXR tells if the data starts with the magic number of an OpenEXR file
+func isOpenEXR(data []byte) bool {
+	return len(data) >= 4 && binary.LittleEndian.Uint32(data) == 20000630
+}
+
+type exrChannel struct {
+	name      string
+	pixelType int32
+}
+
+func (c exrChannel) size() int {
+	if c.pixelType == exrHalf {
+		return 2
+	}
+	return 4
+}
+
+// decodeOpenEXR decodes a single-part scanline OpenEXR image without compression or with RLE or ZIP
+// compression to RGBA floats. The R, G, B and A channels are read, or Y for luminance images.
+func decodeOpenEXR(data []byte) (*textureImage, error) {
+	le := binary.LittleEndian
+	if len(data) < 8 {
+		return nil, fmt.Errorf("exr: the header is truncated")
+	}
+	if flags := le.Uint32(data[4:]); flags&exrUnsupportedFlags != 0 {
+		return nil, fmt.Errorf("exr: tiled, deep and multi-part files are not supported")
+	}
+
+	// The header is a list of named attributes ending with an empty name
+	var channels []exrChannel
+	compression := -1
+	var window [4]int32
+	hasWindow := false
+	pos := 8
+	readString := func() (string, error) {
+		end := bytes.IndexByte(data[pos:], 0)
+		if end < 0 {
+			return "", fmt.Errorf("exr: the header is truncated")
+		}
+		s := string(data[pos : pos+end])
+		pos += end + 1
+		return s, nil
+	}
+	for {
+		name, err := readString()
+		if err != nil {
+			return nil, err
+		}
+		if name == "" {
+			break
+		}
+		if _, err := readString(); err != nil {
+			return nil, err
+		}
+		if pos+4 > len(data) {
+			return nil, fmt.Errorf("exr: the header is truncated")
+		}
+		size := int(le.Uint32(data[pos:]))
+		pos += 4
+		if size < 0 || pos+size > len(data) {
+			return nil, fmt.Errorf("exr: the header is truncated")
+		}
+		value := data[pos : pos+size]
+		pos += size
+
+		switch name {
+		case "channels":
+			if channels, err = parseEXRChannels(value); err != nil {
+				return nil, err
+			}
+		case "compression":
+			if len(value) > 0 {
+				compression = int(value[0])
+			}
+		case "dataWindow":
+			if len(value) >= 16 {
+				for i := range window {
+					window[i] = int32(le.Uint32(value[i*4:]))
+				}
+				hasWindow = true
+			}
+		}
+	}
+
+	if len(channels) == 0 || !hasWindow {
+		return nil, fmt.Errorf("exr: the channels or the data window are missing")
+	}
+	linesPerBlock := 1
+	switch compression {
+	case exrNoCompression, exrRLECompression, exrZIPSCompression:
+	case exrZIPCompression:
+		linesPerBlock = 16
+	default:
+		return nil, fmt.Errorf("exr: compression %d is not supported", compression)
+	}
+
+	width, height := int(window[2])-int(window[0])+1, int(window[3])-int(window[1])+1
+	if width <= 0 || height <= 0 || width*height > maxHDRPixels {
+		return nil, fmt.Errorf("exr: bad data window %dx%d", width, height)
+	}
+	lineSize := 0
+	for _, channel := range channels {
+		lineSize += width * channel.size()
+	}
+
+	// The offset table points at the blocks, each with its first line, size and pixel data
+	blocks := (height + linesPerBlock - 1) / linesPerBlock
+	if pos+blocks*8 > len(data) {
+		return nil, fmt.Errorf("exr: the offset table is truncated")
+	}
+	floats := make([]float32, width*height*4)
+	for i := 3; i < len(floats); i += 4 {
+		floats[i] = 1
+	}
+	for block := 0; block < blocks; block++ {
+		offset := le.Uint64(data[pos+block*8:])
+		if offset+8 > uint64(len(data)) {
+			return nil, fmt.Errorf("exr: block %d is out of range", block)
+		}
+		start := int(offset)
+		firstLine := int(int32(le.Uint32(data[start:]))) - int(window[1])
+		size := int(le.Uint32(data[start+4:]))
+		if size < 0 || start+8+size > len(data) || firstLine < 0 || firstLine >= height {
+			return nil, fmt.Errorf("exr: block %d is out of range", block)
+		}
+
+		lines := linesPerBlock
+		if firstLine+lines > height {
+			lines = height - firstLine
+		}
+		pixels, err := decompressEXRBlock(data[start+8:start+8+size], compression, lines*lineSize)
+		if err != nil {
+			return nil, fmt.Errorf("exr: block %d: %v", block, err)
+		}
+		for line := 0; line < lines; line++ {
+			readEXRLine(pixels[line*lineSize:(line+1)*lineSize], channels, floats[(firstLine+line)*width*4:(firstLine+line+1)*width*4])
+		}
+	}
+	return newFloatTextureImage(width, height, 4, floats), nil
+}
+
+// parseEXRChannels reads the channel list attribute. OpenEXR stores the channels sorted by name,
+// which is also the order of their data in each line.
+func parseEXRChannels(value []byte) ([]exrChannel, error) {
+	le := binary.LittleEndian
+	channels := make([]exrChannel, 0)
+	pos := 0
+	for pos < len(value) && value[pos] != 0 {
+		end := bytes.IndexByte(value[pos:], 0)
+		if end < 0 || pos+end+17 > len(value) {
+			return nil, fmt.Errorf("exr: bad channel list")
+		}
+		name := string(value[pos : pos+end])
+		pos += end + 1
+
+		pixelType := int32(le.Uint32(value[pos:]))
+		xSampling, ySampling := le.Uint32(value[pos+8:]), le.Uint32(value[pos+12:])
+		pos += 16
+		if pixelType < exrUint || pixelType > exrFloat {
+			return nil, fmt.Errorf("exr: channel %s has unknown pixel type %d", name, pixelType)
+		}
+		if xSampling != 1 || ySampling != 1 {
+			return nil, fmt.Errorf("exr: subsampled channel %s is not supported", name)
+		}
+		channels = append(channels, exrChannel{name, pixelType})
+	}
+	sort.SliceStable(channels, func(i, j int) bool { return channels[i].name < channels[j].name })
+	return channels, nil
+}
+
+// decompressEXRBlock returns the raw pixel data of a block
+func decompressEXRBlock(block []byte, compression int, size int) ([]byte, error) {
+	// Blocks that didn't get smaller are stored uncompressed
+	if compression == exrNoCompression || len(block) == size {
+		if len(block) != size {
+			return nil, fmt.Errorf("expected %d bytes, got %d", size, len(block))
+		}
+		return block, nil
+	}
+
+	var encoded []byte
+	if compression == exrRLECompression {
+		encoded = make([]byte, 0, size)
+		for i := 0; i < len(block); {
+			count := int(int8(block[i]))
+			i++
+			if count < 0 {
+				// -count literal bytes
+				if i-count > len(block) {
+					return nil, fmt.Errorf("bad run length")
+				}
+				encoded = append(encoded, block[i:i-count]...)
+				i -= count
+			} else {
+				// count+1 copies of the next byte
+				if i >= len(block) {
+					return nil, fmt.Errorf("bad run length")
+				}
+				for j := 0; j <= count; j++ {
+					encoded = append(encoded, block[i])
+				}
+				i++
+			}
+		}
+	} else {
+		reader, err := zlib.NewReader(bytes.NewReader(block))
+		if err != nil {
+			return nil, err
+		}
+		if encoded, err = ioutil.ReadAll(reader); err != nil {
+			return nil, err
+		}
+	}
+	if len(encoded) != size {
+		return nil, fmt.Errorf("expected %d bytes, got %d", size, len(encoded))
+	}
+
+	// Undo the delta predictor, then interleave the two halves again
+	for i := 1; i < len(encoded); i++ {
+		encoded[i] = encoded[i-1] + encoded[i] - 128
+	}
+	pixels := make([]byte, size)
+	half := (size + 1) / 2
+	for i := range pixels {
+		if i%2 == 0 {
+			pixels[i] = encoded[i/2]
+		} else {
+			pixels[i] = encoded[half+i/2]
+		}
+	}
+	return pixels, nil
+}
+
+// readEXRLine converts the channels of one line to RGBA. Each channel stores the whole line in turn.
+// Channels other than R, G, B, A and Y are skipped.
+func readEXRLine(line []byte, channels []exrChannel, rgba []float32) {
+	le := binary.LittleEndian
+	width := len(rgba) / 4
+	pos := 0
+	for _, channel := range channels {
+		var targets []int
+		switch channel.name {
+		case "R":
+			targets = []int{0}
+		case "G":
+			targets = []int{1}
+		case "B":
+			targets = []int{2}
+		case "A":
+			targets = []int{3}
+		case "Y":
+			targets = []int{0, 1, 2}
+		}
+
+		for x := 0; x < width && targets != nil; x++ {
+			raw := line[pos+x*channel.size():]
+			var value float32
+			switch channel.pixelType {
+			case exrHalf:
+				value = halfToFloat(le.Uint16(raw))
+			case exrFloat:
+				value = math.Float32frombits(le.Uint32(raw))
+			default:
+				value = float32(le.Uint32(raw))
+			}
+			for _, target := range targets {
+				rgba[x*4+target] = value
+			}
+		}
+		pos += width * channel.size()
+	}
+}
+
+// halfToFloat converts an IEEE 754 half precision number
+func halfToFloat(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exponent := uint32(h>>10) & 0x1F
+	mantissa := uint32(h) & 0x3FF
+
+	switch {
+	case exponent == 0 && mantissa == 0:
+		return math.Float32frombits(sign)
+	case exponent == 0:
+		// Subnormal: value = mantissa * 2^-24
+		value := float32(mantissa) / (1 << 24)
+		if sign != 0 {
+			return -value
+		}
+		return value
+	case exponent == 0x1F:
+		// Infinity or NaN
+		return math.Float32frombits(sign | 0xFF<<23 | mantissa<<13)
+	}
+	return math.Float32frombits(sign | (exponent+127-15)<<23 | mantissa<<13)
+}
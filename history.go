@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/go-gl/gl/v3.2-core/gl"
+	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/inkyblackness/imgui-go"
+)
+
+// command is an editor action that can be undone and redone
+type command interface {
+	description() string
+	undo()
+	redo()
+	// merge folds the next command into this one, e.g. the frames of a single drag, and reports if it did
+	merge(next command) bool
+}
+
+// maxHistory is the number of commands kept for undo
+const maxHistory = 200
+
+// history is the undo and redo stack of the editor
+type history struct {
+	done   []command
+	undone []command
+	// sealed stops the next command from merging into the last one. It is set when the mouse is released.
+	sealed bool
+	// replaying is set while a command is undone or redone, so the changes it makes aren't recorded again
+	replaying bool
+}
+
+// undoHistory records the actions of every editor panel
+var undoHistory history
+
+// push records a command that was just executed and drops the commands that could be redone
+func (h *history) push(c command) {
+	if h.replaying {
+		return
+	}
+	h.undone = nil
+
+	if n := len(h.done); n > 0 && !h.sealed && h.done[n-1].merge(c) {
+		return
+	}
+	h.done = append(h.done, c)
+	if len(h.done) > maxHistory {
+		h.done = h.done[1:]
+	}
+	h.sealed = false
+}
+
+// seal ends the current interaction, so the next command gets its own entry
+func (h *history) seal() {
+	h.sealed = true
+}
+
+func (h *history) undo() {
+	if len(h.done) == 0 {
+		return
+	}
+	c := h.done[len(h.done)-1]
+	h.done = h.done[:len(h.done)-1]
+
+	h.replaying = true
+	c.undo()
+	h.replaying = false
+
+	h.undone = append(h.undone, c)
+	h.sealed = true
+}
+
+func (h *history) redo() {
+	if len(h.undone) == 0 {
+		return
+	}
+	c := h.undone[len(h.undone)-1]
+	h.undone = h.undone[:len(h.undone)-1]
+
+	h.replaying = true
+	c.redo()
+	h.replaying = false
+
+	h.done = append(h.done, c)
+	h.sealed = true
+}
+
+// handleShortcuts undoes on Ctrl+Z and redoes on Ctrl+Y, unless a text field has the keyboard
+func (h *history) handleShortcuts() {
+	if imgui.CurrentIO().WantTextInput() {
+		return
+	}
+	if !imgui.IsKeyDown(int(glfw.KeyLeftControl)) && !imgui.IsKeyDown(int(glfw.KeyRightControl)) {
+		return
+	}
+	if imgui.IsKeyPressed(int(glfw.KeyZ)) {
+		h.undo()
+	} else if imgui.IsKeyPressed(int(glfw.KeyY)) {
+		h.redo()
+	}
+}
+
+// drawUI lists the commands. Clicking an entry undoes or redoes up to it.
+func (h *history) drawUI() {
+	if imgui.Button("Undo") {
+		h.undo()
+	}
+	imgui.SameLine()
+	if imgui.Button("Redo") {
+		h.redo()
+	}
+	imgui.Separator()
+
+	if imgui.SelectableV("(start)", len(h.done) == 0, 0, imgui.Vec2{}) {
+		for len(h.done) > 0 {
+			h.undo()
+		}
+	}
+	for i := 0; i < len(h.done); i++ {
+		if imgui.SelectableV(fmt.Sprintf("%s##done%d", h.done[i].description(), i), i == len(h.done)-1, 0, imgui.Vec2{}) {
+			for len(h.done) > i+1 {
+				h.undo()
+			}
+		}
+	}
+
+	// Undone commands are listed in the order they would be redone
+	for i := len(h.undone) - 1; i >= 0; i-- {
+		imgui.PushStyleColor(imgui.StyleColorText, imgui.Vec4{X: 0.5, Y: 0.5, Z: 0.5, W: 1})
+		clicked := imgui.SelectableV(fmt.Sprintf("%s##undone%d", h.undone[i].description(), i), false, 0, imgui.Vec2{})
+		imgui.PopStyleColor()
+		if clicked {
+			for len(h.undone) > i {
+				h.redo()
+			}
+		}
+	}
+}
+
+// fieldCommand is an edit of a material field, including texture paths and sampler settings. Extra
+// passes are released when they are removed or the material is replaced, so they are referred to by
+// their index in the root material. A pass of -1 is the root material itself.
+type fieldCommand struct {
+	root          *material
+	pass          int
+	before, after fieldValue
+}
+
+func (c *fieldCommand) description() string { return "Edit " + c.after.Name }
+func (c *fieldCommand) undo()               { c.apply(c.before) }
+func (c *fieldCommand) redo()               { c.apply(c.after) }
+
+func (c *fieldCommand) apply(value fieldValue) {
+	if c.pass < 0 {
+		c.root.setFieldValue(value)
+	} else if c.pass < len(c.root.passes) {
+		c.root.passes[c.pass].setFieldValue(value)
+	}
+}
+
+// merge joins the edits of the same numeric field. Texture changes are confirmed one by one.
+func (c *fieldCommand) merge(next command) bool {
+	n, ok := next.(*fieldCommand)
+	if !ok || n.root != c.root || n.pass != c.pass || n.after.Name != c.after.Name || c.after.Type.isTexture() {
+		return false
+	}
+	c.after = n.after
+	return true
+}
+
+// forgetPass drops the commands of a pass that is released and renumbers those of the passes after it
+func (h *history) forgetPass(root *material, pass int) {
+	h.done = forgetPassCommands(h.done, root, pass)
+	h.undone = forgetPassCommands(h.undone, root, pass)
+}
+
+func forgetPassCommands(commands []command, root *material, pass int) []command {
+	kept := commands[:0]
+	for _, c := range commands {
+		if f, ok := c.(*fieldCommand); ok && f.root == root {
+			if f.pass == pass {
+				continue
+			}
+			if f.pass > pass {
+				f.pass--
+			}
+		}
+		kept = append(kept, c)
+	}
+	return kept
+}
+
+// setFieldValue sets and uploads the value of the named field, if it still has the same type
+func (m *material) setFieldValue(value fieldValue) {
+	field := m.findField(value.Name)
+	if field == nil || field.fieldType() != value.Type {
+		log.Printf("WARNING: material field %q no longer exists in the shader", value.Name)
+		return
+	}
+	field.setValue(value)
+	gl.UseProgram(m.shader.program)
+	field.apply(m)
+	m.rebuildTexBindings()
+}
+
+// valueCommand is an edit of float properties outside the materials, such as the light or the clear color
+type valueCommand struct {
+	label         string
+	target        []*float32
+	before, after []float32
+}
+
+// newValueCommand records the change of the target from the given values to its current ones
+func newValueCommand(label string, target []*float32, before []float32) *valueCommand {
+	return &valueCommand{label: label, target: target, before: before, after: readFloats(target)}
+}
+
+func (c *valueCommand) description() string { return "Edit " + c.label }
+func (c *valueCommand) undo()               { writeFloats(c.target, c.before) }
+func (c *valueCommand) redo()               { writeFloats(c.target, c.after) }
+
+func (c *valueCommand) merge(next command) bool {
+	n, ok := next.(*valueCommand)
+	if !ok || n.label != c.label {
+		return false
+	}
+	c.after = n.after
+	return true
+}
+
+// floatPointers returns a pointer to every element, e.g. of the slice of a global vector
+func floatPointers(values []float32) []*float32 {
+	pointers := make([]*float32, len(values))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	return pointers
+}
+
+func readFloats(pointers []*float32) []float32 {
+	values := make([]float32, len(pointers))
+	for i, pointer := range pointers {
+		values[i] = *pointer
+	}
+	return values
+}
+
+func writeFloats(pointers []*float32, values []float32) {
+	for i, pointer := range pointers {
+		*pointer = values[i]
+	}
+}
+
+// modelCommand is a switch of the displayed model
+type modelCommand struct {
+	state                   *state
+	beforeName, afterName   string
+	beforeVerts, afterVerts []float32
+}
+
+func (c *modelCommand) description() string { return "Model " + c.afterName }
+func (c *modelCommand) undo()               { setActiveModel(c.state, c.beforeName, c.beforeVerts) }
+func (c *modelCommand) redo()               { setActiveModel(c.state, c.afterName, c.afterVerts) }
+func (c *modelCommand) merge(next command) bool {
+	return false
+}
+
+// shaderCommand is a switch to another shader, by compiling new source paths or loading a material.
+// Undoing compiles the previous shader again and restores the values it had.
+type shaderCommand struct {
+	state         *state
+	before, after materialFile
+}
+
+func (c *shaderCommand) description() string {
+	if c.after.GLSL != "" {
+		return "Shader " + c.after.GLSL
+	}
+	return "Shader " + c.after.Fragment
+}
+func (c *shaderCommand) undo() { restoreShader(c.state, c.before) }
+func (c *shaderCommand) redo() { restoreShader(c.state, c.after) }
+func (c *shaderCommand) merge(next command) bool {
+	return false
+}
+
+// sameShader tells if two material files reference the same shader files
+func sameShader(a materialFile, b materialFile) bool {
+	return a.Vertex == b.Vertex && a.Fragment == b.Fragment && a.Geometry == b.Geometry && a.GLSL == b.GLSL
+}
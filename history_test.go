@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestHistoryUndoRedo(t *testing.T) {
+	var h history
+	value := float32(0)
+	edit := func(label string, to float32) {
+		before := []float32{value}
+		value = to
+		h.push(newValueCommand(label, []*float32{&value}, before))
+	}
+
+	// The frames of one drag merge into a single entry
+	edit("speed", 1)
+	edit("speed", 2)
+	edit("speed", 3)
+	assert.Equal(t, len(h.done), 1)
+
+	// Releasing the mouse ends the drag
+	h.seal()
+	edit("speed", 4)
+	assert.Equal(t, len(h.done), 2)
+
+	// Another target never merges
+	edit("scale", 5)
+	assert.Equal(t, len(h.done), 3)
+
+	h.undo()
+	assert.Equal(t, value, float32(4))
+	h.undo()
+	assert.Equal(t, value, float32(3))
+	h.undo()
+	assert.Equal(t, value, float32(0))
+	h.undo()
+	assert.Equal(t, value, float32(0))
+	assert.Equal(t, len(h.undone), 3)
+
+	h.redo()
+	assert.Equal(t, value, float32(3))
+
+	// An undo ends the interaction, so the next edit isn't merged into the redone entry
+	edit("speed", 6)
+	assert.Equal(t, len(h.done), 2)
+	assert.Equal(t, len(h.undone), 0)
+	h.undo()
+	assert.Equal(t, value, float32(3))
+}
+
+func TestHistoryReplay(t *testing.T) {
+	var h history
+	value := float32(1)
+	target := []*float32{&value}
+	h.push(newValueCommand("speed", target, []float32{0}))
+
+	// Changes made while replaying aren't recorded again
+	h.replaying = true
+	h.push(newValueCommand("speed", target, []float32{7}))
+	h.replaying = false
+	assert.Equal(t, len(h.done), 1)
+
+	for i := 0; i < maxHistory+10; i++ {
+		h.seal()
+		h.push(newValueCommand("speed", target, []float32{0}))
+	}
+	assert.Equal(t, len(h.done), maxHistory)
+}
+
+func TestHistoryForgetPass(t *testing.T) {
+	var h history
+	root := &material{}
+	for _, pass := range []int{-1, 0, 1, 2} {
+		h.seal()
+		h.push(&fieldCommand{root: root, pass: pass, after: fieldValue{Name: "color"}})
+	}
+	h.undo()
+
+	// The commands of a removed pass go, those of the passes after it follow the new indices
+	h.forgetPass(root, 1)
+	assert.Equal(t, len(h.done), 2)
+	assert.Equal(t, h.done[1].(*fieldCommand).pass, 0)
+	assert.Equal(t, h.undone[0].(*fieldCommand).pass, 1)
+
+	// Passes that no longer exist are skipped on undo
+	h.redo()
+	h.undo()
+	assert.Equal(t, len(h.undone), 1)
+}
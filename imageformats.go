@@ -0,0 +1,499 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-gl/gl/v3.2-core/gl"
+)
+
+// textureImage is decoded pixel data in the layout it is uploaded in. Rows go from the bottom to the
+// top, so the image isn't upside down with the texture coordinate origin of OpenGL in the lower left.
+type textureImage struct {
+	width, height int
+	channels      int
+	// pixels holds the 8-bit channels of LDR images, floats the channels of HDR images
+	pixels []uint8
+	floats []float32
+}
+
+// pixelFormat is how the channels of an image are stored on the GPU
+type pixelFormat struct {
+	name           string
+	internalFormat int32
+	format         uint32
+	xtype          uint32
+	bytesPerPixel  int
+}
+
+// LDR images keep one and two channels, three channel images are padded to RGBA
+var byteFormats = map[int]pixelFormat{
+	1: {"R8", gl.R8, gl.RED, gl.UNSIGNED_BYTE, 1},
+	2: {"RG8", gl.RG8, gl.RG, gl.UNSIGNED_BYTE, 2},
+	4: {"RGBA8", gl.RGBA8, gl.RGBA, gl.UNSIGNED_BYTE, 4},
+}
+
+// HDR colors are stored at half precision, images with alpha at full precision
+var floatFormats = map[int]pixelFormat{
+	3: {"RGB16F", gl.RGB16F, gl.RGB, gl.FLOAT, 6},
+	4: {"RGBA32F", gl.RGBA32F, gl.RGBA, gl.FLOAT, 16},
+}
+
+func (img *textureImage) format() pixelFormat {
+	if img.floats != nil {
+		return floatFormats[img.channels]
+	}
+	return byteFormats[img.channels]
+}
+
+// bytes is the GPU memory of the image without mipmaps
+func (img *textureImage) bytes() int {
+	return img.width * img.height * img.format().bytesPerPixel
+}
+
+// flip reverses the order of the rows in place
+func (img *textureImage) flip() {
+	rowLength := img.width * img.channels
+	for y := 0; y < img.height/2; y++ {
+		top, bottom := y*rowLength, (img.height-1-y)*rowLength
+		for x := 0; x < rowLength; x++ {
+			if img.floats != nil {
+				img.floats[top+x], img.floats[bottom+x] = img.floats[bottom+x], img.floats[top+x]
+			} else {
+				img.pixels[top+x], img.pixels[bottom+x] = img.pixels[bottom+x], img.pixels[top+x]
+			}
+		}
+	}
+}
+
+// newTextureImage converts a decoded image, whose rows go from top to bottom. Gray images keep one
+// channel and gray images with alpha two, everything else becomes RGBA.
+func newTextureImage(img image.Image) *textureImage {
+	bounds := img.Bounds()
+	t := &textureImage{width: bounds.Dx(), height: bounds.Dy()}
+
+	switch src := img.(type) {
+	case *image.Gray:
+		t.channels = 1
+		t.pixels = make([]uint8, 0, t.width*t.height)
+		for y := 0; y < t.height; y++ {
+			start := src.PixOffset(bounds.Min.X, bounds.Min.Y+y)
+			t.pixels = append(t.pixels, src.Pix[start:start+t.width]...)
+		}
+	case *image.Gray16:
+		t.channels = 1
+		t.pixels = make([]uint8, 0, t.width*t.height)
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				t.pixels = append(t.pixels, uint8(src.Gray16At(x, y).Y>>8))
+			}
+		}
+	case *grayAlphaImage:
+		t.channels = 2
+		t.pixels = make([]uint8, 0, t.width*t.height*2)
+		for y := 0; y < t.height; y++ {
+			start := src.pixOffset(bounds.Min.X, bounds.Min.Y+y)
+			t.pixels = append(t.pixels, src.pix[start:start+t.width*2]...)
+		}
+	default:
+		rgba := image.NewRGBA(image.Rect(0, 0, t.width, t.height))
+		draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+		t.channels = 4
+		t.pixels = rgba.Pix
+	}
+
+	t.flip()
+	return t
+}
+
+// newFloatTextureImage takes the channels of an HDR image, whose rows go from top to bottom
+func newFloatTextureImage(width, height, channels int, floats []float32) *textureImage {
+	img := &textureImage{width: width, height: height, channels: channels, floats: floats}
+	img.flip()
+	return img
+}
+
+// decodeImage reads an image file. PNG, JPEG, GIF, BMP and TGA files are decoded to 8-bit channels,
+// Radiance .hdr and OpenEXR files to floats.
+func decodeImage(filePath string) (*textureImage, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("texture %q not found on disk: %v", filePath, err)
+	}
+
+	switch {
+	case isRadianceHDR(data):
+		return decodeRadianceHDR(data)
+	case isOpenEXR(data):
+		return decodeOpenEXR(data)
+	case strings.EqualFold(filepath.Ext(filePath), ".tga"):
+		// TGA files have no signature to detect them by
+		img, err := decodeTGA(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filePath, err)
+		}
+		return newTextureImage(img), nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filePath, err)
+	}
+	if pngColorType(data) == pngGrayAlpha {
+		// The PNG decoder expands gray and alpha to RGBA
+		img = newGrayAlphaImage(img)
+	}
+	return newTextureImage(img), nil
+}
+
+// pngGrayAlpha is the color type of PNG files with a gray and an alpha channel
+const pngGrayAlpha = 4
+
+// pngColorType returns the color type from the header of a PNG file, or -1 for other files
+func pngColorType(data []byte) int {
+	const signature = "\x89PNG\r\n\x1a\n"
+	// The IHDR chunk comes first: length, type, width, height, bit depth and then the color type
+	if len(data) < 26 || string(data[:8]) != signature || string(data[12:16]) != "IHDR" {
+		return -1
+	}
+	return int(data[25])
+}
+
+// grayAlphaImage is an image with a gray and an alpha channel per pixel, which the image package has no type for
+type grayAlphaImage struct {
+	pix  []uint8
+	rect image.Rectangle
+}
+
+func newGrayAlphaImage(img image.Image) *grayAlphaImage {
+	bounds := img.Bounds()
+	ga := &grayAlphaImage{pix: make([]uint8, 0, bounds.Dx()*bounds.Dy()*2), rect: bounds}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			ga.pix = append(ga.pix, c.R, c.A)
+		}
+	}
+	return ga
+}
+
+func (ga *grayAlphaImage) pixOffset(x, y int) int {
+	return ((y-ga.rect.Min.Y)*ga.rect.Dx() + x - ga.rect.Min.X) * 2
+}
+
+func (ga *grayAlphaImage) ColorModel() color.Model { return color.NRGBAModel }
+func (ga *grayAlphaImage) Bounds() image.Rectangle { return ga.rect }
+func (ga *grayAlphaImage) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(ga.rect)) {
+		return color.NRGBA{}
+	}
+	i := ga.pixOffset(x, y)
+	return color.NRGBA{ga.pix[i], ga.pix[i], ga.pix[i], ga.pix[i+1]}
+}
+
+func init() {
+	image.RegisterFormat("bmp", "BM", func(r io.Reader) (image.Image, error) {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return decodeBMP(data)
+	}, func(r io.Reader) (image.Config, error) {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return image.Config{}, err
+		}
+		img, err := decodeBMP(data)
+		if err != nil {
+			return image.Config{}, err
+		}
+		return image.Config{ColorModel: img.ColorModel(), Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}, nil
+	})
+}
+
+// BMP compression methods
+const (
+	bmpRGB       = 0
+	bmpBitfields = 3
+)
+
+// decodeBMP decodes uncompressed BMP files with 1, 4, 8, 16, 24 or 32 bits per pixel
+func decodeBMP(data []byte) (image.Image, error) {
+	if len(data) < 54 || string(data[:2]) != "BM" {
+		return nil, fmt.Errorf("bmp: not a BMP file")
+	}
+	le := binary.LittleEndian
+	pixelOffset := int(le.Uint32(data[10:]))
+	headerSize := int(le.Uint32(data[14:]))
+	if headerSize < 40 {
+		return nil, fmt.Errorf("bmp: OS/2 bitmaps are not supported")
+	}
+	width := int(int32(le.Uint32(data[18:])))
+	height := int(int32(le.Uint32(data[22:])))
+	bitsPerPixel := int(le.Uint16(data[28:]))
+	compression := le.Uint32(data[30:])
+	paletteSize := int(le.Uint32(data[46:]))
+
+	// Rows are stored from the bottom up unless the height is negative
+	bottomUp := height > 0
+	if height < 0 {
+		height = -height
+	}
+	if width <= 0 || height == 0 {
+		return nil, fmt.Errorf("bmp: bad size %dx%d", width, height)
+	}
+	if compression != bmpRGB && compression != bmpBitfields {
+		return nil, fmt.Errorf("bmp: compression %d is not supported", compression)
+	}
+
+	// Compare by division, as stride*height overflows for huge sizes from a corrupt header
+	stride := (int64(bitsPerPixel)*int64(width) + 31) / 32 * 4
+	if pixelOffset > len(data) || stride > int64((len(data)-pixelOffset)/height) {
+		return nil, fmt.Errorf("bmp: the pixel data is truncated")
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	var palette []color.NRGBA
+	var masks [4]uint32
+	switch bitsPerPixel {
+	case 1, 4, 8:
+		if paletteSize == 0 {
+			paletteSize = 1 << uint(bitsPerPixel)
+		}
+		start := 14 + headerSize
+		if start+paletteSize*4 > len(data) {
+			return nil, fmt.Errorf("bmp: the palette is truncated")
+		}
+		for i := 0; i < paletteSize; i++ {
+			entry := data[start+i*4:]
+			palette = append(palette, color.NRGBA{entry[2], entry[1], entry[0], 0xFF})
+		}
+	case 16:
+		masks = [4]uint32{0x7C00, 0x3E0, 0x1F, 0}
+	case 24, 32:
+		masks = [4]uint32{0xFF0000, 0xFF00, 0xFF, 0}
+	default:
+		return nil, fmt.Errorf("bmp: %d bits per pixel are not supported", bitsPerPixel)
+	}
+	if compression == bmpBitfields {
+		// The masks follow a 40 byte header and are part of the larger headers
+		count := 3
+		if headerSize >= 56 {
+			count = 4
+		}
+		if 54+count*4 > len(data) {
+			return nil, fmt.Errorf("bmp: the color masks are truncated")
+		}
+		for i := 0; i < count; i++ {
+			masks[i] = le.Uint32(data[54+i*4:])
+		}
+	}
+
+	for row := 0; row < height; row++ {
+		y := row
+		if bottomUp {
+			y = height - 1 - row
+		}
+		line := data[pixelOffset+row*int(stride):]
+		for x := 0; x < width; x++ {
+			var c color.NRGBA
+			switch bitsPerPixel {
+			case 1, 4, 8:
+				bit := x * bitsPerPixel
+				index := int(line[bit/8]>>uint(8-bitsPerPixel-bit%8)) & (1<<uint(bitsPerPixel) - 1)
+				if index >= len(palette) {
+					return nil, fmt.Errorf("bmp: palette index %d out of range", index)
+				}
+				c = palette[index]
+			case 16:
+				c = bmpMaskedColor(uint32(le.Uint16(line[x*2:])), masks)
+			case 24:
+				c = bmpMaskedColor(uint32(line[x*3])|uint32(line[x*3+1])<<8|uint32(line[x*3+2])<<16, masks)
+			case 32:
+				c = bmpMaskedColor(le.Uint32(line[x*4:]), masks)
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img, nil
+}
+
+// bmpMaskedColor extracts the red, green, blue and alpha bits of a pixel. Without an alpha mask the pixel is opaque.
+func bmpMaskedColor(pixel uint32, masks [4]uint32) color.NRGBA {
+	var channels [4]uint8
+	for i, mask := range masks {
+		if mask == 0 {
+			channels[i] = 0xFF
+			continue
+		}
+		shift := uint(0)
+		for mask>>shift&1 == 0 {
+			shift++
+		}
+		max := mask >> shift
+		channels[i] = uint8(uint64((pixel&mask)>>shift) * 0xFF / uint64(max))
+	}
+	return color.NRGBA{channels[0], channels[1], channels[2], channels[3]}
+}
+
+// TGA image types. The run length encoded types add 8.
+const (
+	tgaColorMapped = 1
+	tgaTrueColor   = 2
+	tgaGray        = 3
+	tgaRLE         = 8
+)
+
+// decodeTGA decodes color mapped, true color and gray TGA files, optionally run length encoded.
+// 8-bit gray files keep one channel and 16-bit gray files two.
+func decodeTGA(data []byte) (image.Image, error) {
+	if len(data) < 18 {
+		return nil, fmt.Errorf("tga: the header is truncated")
+	}
+	le := binary.LittleEndian
+	idLength := int(data[0])
+	imageType := int(data[2])
+	mapFirst := int(le.Uint16(data[3:]))
+	mapLength := int(le.Uint16(data[5:]))
+	mapBits := int(data[7])
+	width := int(le.Uint16(data[12:]))
+	height := int(le.Uint16(data[14:]))
+	bitsPerPixel := int(data[16])
+	descriptor := data[17]
+
+	rle := imageType&tgaRLE != 0
+	baseType := imageType &^ tgaRLE
+	if width == 0 || height == 0 {
+		return nil, fmt.Errorf("tga: bad size %dx%d", width, height)
+	}
+
+	bytesPerPixel := (bitsPerPixel + 7) / 8
+	switch {
+	case baseType == tgaColorMapped && bitsPerPixel == 8:
+	case baseType == tgaTrueColor && (bitsPerPixel == 16 || bitsPerPixel == 24 || bitsPerPixel == 32):
+	case baseType == tgaGray && (bitsPerPixel == 8 || bitsPerPixel == 16):
+	default:
+		return nil, fmt.Errorf("tga: image type %d with %d bits per pixel is not supported", imageType, bitsPerPixel)
+	}
+
+	pos := 18 + idLength
+	if pos > len(data) {
+		return nil, fmt.Errorf("tga: the image ID is truncated")
+	}
+	var palette []color.NRGBA
+	if data[1] != 0 {
+		if mapBits != 15 && mapBits != 16 && mapBits != 24 && mapBits != 32 {
+			return nil, fmt.Errorf("tga: color map entries with %d bits are not supported", mapBits)
+		}
+		mapBytes := (mapBits + 7) / 8
+		if pos+mapLength*mapBytes > len(data) {
+			return nil, fmt.Errorf("tga: the color map is truncated")
+		}
+		for i := 0; i < mapLength; i++ {
+			palette = append(palette, tgaColor(data[pos+i*mapBytes:], mapBits))
+		}
+		pos += mapLength * mapBytes
+	}
+
+	// Expand the run length encoding into plain pixel data
+	count := width * height
+	pixels := data[pos:]
+	if rle {
+		pixels = make([]byte, 0, count*bytesPerPixel)
+		for len(pixels) < count*bytesPerPixel {
+			if pos >= len(data) {
+				return nil, fmt.Errorf("tga: the pixel data is truncated")
+			}
+			header := int(data[pos])
+			pos++
+			run := header&0x7F + 1
+			if header&0x80 != 0 {
+				if pos+bytesPerPixel > len(data) {
+					return nil, fmt.Errorf("tga: the pixel data is truncated")
+				}
+				for i := 0; i < run; i++ {
+					pixels = append(pixels, data[pos:pos+bytesPerPixel]...)
+				}
+				pos += bytesPerPixel
+			} else {
+				if pos+run*bytesPerPixel > len(data) {
+					return nil, fmt.Errorf("tga: the pixel data is truncated")
+				}
+				pixels = append(pixels, data[pos:pos+run*bytesPerPixel]...)
+				pos += run * bytesPerPixel
+			}
+		}
+	}
+	if len(pixels) < count*bytesPerPixel {
+		return nil, fmt.Errorf("tga: the pixel data is truncated")
+	}
+
+	// Rows go from the bottom up and columns from left to right unless the descriptor says otherwise
+	topDown := descriptor&0x20 != 0
+	rightToLeft := descriptor&0x10 != 0
+	position := func(i int) (int, int) {
+		x, y := i%width, i/width
+		if rightToLeft {
+			x = width - 1 - x
+		}
+		if !topDown {
+			y = height - 1 - y
+		}
+		return x, y
+	}
+
+	rect := image.Rect(0, 0, width, height)
+	switch {
+	case baseType == tgaGray && bitsPerPixel == 8:
+		img := image.NewGray(rect)
+		for i := 0; i < count; i++ {
+			x, y := position(i)
+			img.Pix[img.PixOffset(x, y)] = pixels[i]
+		}
+		return img, nil
+	case baseType == tgaGray:
+		img := &grayAlphaImage{pix: make([]uint8, count*2), rect: rect}
+		for i := 0; i < count; i++ {
+			x, y := position(i)
+			copy(img.pix[img.pixOffset(x, y):], pixels[i*2:i*2+2])
+		}
+		return img, nil
+	}
+
+	img := image.NewNRGBA(rect)
+	for i := 0; i < count; i++ {
+		x, y := position(i)
+		if baseType == tgaColorMapped {
+			index := int(pixels[i]) - mapFirst
+			if index < 0 || index >= len(palette) {
+				return nil, fmt.Errorf("tga: color map index %d out of range", pixels[i])
+			}
+			img.SetNRGBA(x, y, palette[index])
+		} else {
+			img.SetNRGBA(x, y, tgaColor(pixels[i*bytesPerPixel:], bitsPerPixel))
+		}
+	}
+	return img, nil
+}
+
+// tgaColor reads a BGR, BGRA or 5-5-5 color
+func tgaColor(p []byte, bits int) color.NRGBA {
+	switch bits {
+	case 15, 16:
+		v := uint16(p[0]) | uint16(p[1])<<8
+		expand := func(c uint16) uint8 { return uint8((c & 0x1F) * 0xFF / 0x1F) }
+		return color.NRGBA{expand(v >> 10), expand(v >> 5), expand(v), 0xFF}
+	case 32:
+		return color.NRGBA{p[2], p[1], p[0], p[3]}
+	default:
+		return color.NRGBA{p[2], p[1], p[0], 0xFF}
+	}
+}
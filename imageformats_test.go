@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestTextureImageLayout(t *testing.T) {
+	// Rows are flipped so the first uploaded row is the bottom of the image
+	gray := image.NewGray(image.Rect(0, 0, 3, 2))
+	copy(gray.Pix, []uint8{1, 2, 3, 4, 5, 6})
+	img := newTextureImage(gray)
+	assert.Equal(t, img.channels, 1)
+	assert.DeepEqual(t, img.pixels, []uint8{4, 5, 6, 1, 2, 3})
+	assert.Equal(t, img.format().name, "R8")
+	assert.Equal(t, img.bytes(), 6)
+
+	ga := &grayAlphaImage{pix: []uint8{10, 255, 20, 128}, rect: image.Rect(0, 0, 1, 2)}
+	img = newTextureImage(ga)
+	assert.Equal(t, img.channels, 2)
+	assert.DeepEqual(t, img.pixels, []uint8{20, 128, 10, 255})
+	assert.Equal(t, img.format().name, "RG8")
+
+	rgba := image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	rgba.SetNRGBA(0, 0, color.NRGBA{1, 2, 3, 255})
+	img = newTextureImage(rgba)
+	assert.Equal(t, img.channels, 4)
+	assert.DeepEqual(t, img.pixels, []uint8{1, 2, 3, 255})
+
+	img = newFloatTextureImage(1, 2, 3, []float32{1, 2, 3, 4, 5, 6})
+	assert.DeepEqual(t, img.floats, []float32{4, 5, 6, 1, 2, 3})
+	assert.Equal(t, img.format().name, "RGB16F")
+	assert.Equal(t, img.bytes(), 12)
+
+	// The repo textures still decode
+	img, err := decodeImage("Assets/wood.png")
+	assert.NilError(t, err)
+	assert.Assert(t, img.width > 0 && img.height > 0)
+	assert.Equal(t, len(img.pixels), img.width*img.height*img.channels)
+}
+
+func TestPNGColorType(t *testing.T) {
+	header := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x04")
+	assert.Equal(t, pngColorType(header), pngGrayAlpha)
+	assert.Equal(t, pngColorType([]byte("BM")), -1)
+}
+
+func TestDecodeBMP(t *testing.T) {
+	// 2x2 24-bit bottom-up BMP: the first stored row is the bottom one, rows are padded to 4 bytes
+	le := binary.LittleEndian
+	data := make([]byte, 54)
+	copy(data, "BM")
+	le.PutUint32(data[10:], 54)
+	le.PutUint32(data[14:], 40)
+	le.PutUint32(data[18:], 2)
+	le.PutUint32(data[22:], 2)
+	le.PutUint16(data[26:], 1)
+	le.PutUint16(data[28:], 24)
+	data = append(data,
+		0, 0, 255, 0, 255, 0, 0, 0, // bottom: red, green
+		255, 0, 0, 255, 255, 255, 0, 0) // top: blue, white
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	assert.NilError(t, err)
+	assert.Equal(t, img.Bounds(), image.Rect(0, 0, 2, 2))
+	assert.Equal(t, color.NRGBAModel.Convert(img.At(0, 0)), color.NRGBA{0, 0, 255, 255})
+	assert.Equal(t, color.NRGBAModel.Convert(img.At(1, 0)), color.NRGBA{255, 255, 255, 255})
+	assert.Equal(t, color.NRGBAModel.Convert(img.At(0, 1)), color.NRGBA{255, 0, 0, 255})
+
+	le.PutUint16(data[28:], 12)
+	_, err = decodeBMP(data)
+	assert.ErrorContains(t, err, "12 bits per pixel")
+
+	// Corrupt headers are rejected instead of reading past the end
+	le.PutUint16(data[28:], 24)
+	huge := append([]byte(nil), data...)
+	le.PutUint32(huge[18:], 1<<31-1)
+	le.PutUint32(huge[22:], 1<<31-1)
+	_, err = decodeBMP(huge)
+	assert.ErrorContains(t, err, "truncated")
+
+	le.PutUint16(data[28:], 32)
+	le.PutUint32(data[30:], bmpBitfields)
+	_, err = decodeBMP(data[:60])
+	assert.ErrorContains(t, err, "truncated")
+}
+
+func tgaHeader(imageType byte, width, height uint16, bits byte, descriptor byte) []byte {
+	header := make([]byte, 18)
+	header[2] = imageType
+	binary.LittleEndian.PutUint16(header[12:], width)
+	binary.LittleEndian.PutUint16(header[14:], height)
+	header[16] = bits
+	header[17] = descriptor
+	return header
+}
+
+func TestDecodeTGA(t *testing.T) {
+	// Run length encoded BGRA, top-down: a run of two red pixels, then one raw blue pixel
+	data := append(tgaHeader(tgaTrueColor|tgaRLE, 3, 1, 32, 0x28),
+		0x81, 0, 0, 255, 255,
+		0x00, 255, 0, 0, 128)
+	img, err := decodeTGA(data)
+	assert.NilError(t, err)
+	assert.Equal(t, color.NRGBAModel.Convert(img.At(1, 0)), color.NRGBA{255, 0, 0, 255})
+	assert.Equal(t, color.NRGBAModel.Convert(img.At(2, 0)), color.NRGBA{0, 0, 255, 128})
+
+	// Gray, bottom-up by default
+	data = append(tgaHeader(tgaGray, 1, 2, 8, 0), 10, 20)
+	img, err = decodeTGA(data)
+	assert.NilError(t, err)
+	gray, ok := img.(*image.Gray)
+	assert.Assert(t, ok)
+	assert.DeepEqual(t, gray.Pix, []uint8{20, 10})
+
+	// Gray and alpha keep two channels
+	data = append(tgaHeader(tgaGray, 1, 1, 16, 0x20), 10, 200)
+	img, err = decodeTGA(data)
+	assert.NilError(t, err)
+	assert.Equal(t, newTextureImage(img).channels, 2)
+
+	_, err = decodeTGA(append(tgaHeader(tgaTrueColor, 2, 2, 24, 0), 1, 2, 3))
+	assert.ErrorContains(t, err, "truncated")
+
+	data = tgaHeader(tgaTrueColor, 1, 1, 24, 0)
+	data[0] = 200
+	_, err = decodeTGA(data)
+	assert.ErrorContains(t, err, "truncated")
+
+	data = append(tgaHeader(tgaColorMapped, 1, 1, 8, 0), 1, 0)
+	data[1], data[5], data[7] = 1, 1, 8
+	_, err = decodeTGA(data)
+	assert.ErrorContains(t, err, "8 bits are not supported")
+}
+
+func TestDecodeRadianceHDR(t *testing.T) {
+	header := "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n\n-Y 2 +X 8\n"
+
+	// The first line is flat: 1.0 red in every pixel
+	data := []byte(header)
+	for x := 0; x < 8; x++ {
+		data = append(data, 128, 0, 0, 129)
+	}
+	// The second line is run length encoded per channel: 0.5 green in every pixel
+	data = append(data, 2, 2, 0, 8)
+	data = append(data, 128+8, 0)   // red
+	data = append(data, 128+8, 128) // green
+	data = append(data, 128+8, 0)   // blue
+	data = append(data, 128+8, 128) // exponent
+
+	assert.Assert(t, isRadianceHDR(data))
+	img, err := decodeRadianceHDR(data)
+	assert.NilError(t, err)
+	assert.Equal(t, img.width, 8)
+	assert.Equal(t, img.channels, 3)
+	// The top line ends up last
+	assert.DeepEqual(t, img.floats[:3], []float32{0, 0.5, 0})
+	assert.DeepEqual(t, img.floats[len(img.floats)-3:], []float32{1, 0, 0})
+
+	_, err = decodeRadianceHDR([]byte("#?RADIANCE\nFORMAT=32-bit_rle_xyze\n\n-Y 1 +X 1\n"))
+	assert.ErrorContains(t, err, "not supported")
+
+	_, err = decodeRadianceHDR([]byte("#?RADIANCE\n\n-Y 100000 +X 100000\n"))
+	assert.ErrorContains(t, err, "bad resolution")
+}
+
+// encodeEXR writes a scanline OpenEXR file with half G and float R channels
+func encodeEXR(t *testing.T, width, height int, compression byte, r, g []float32) []byte {
+	le := binary.LittleEndian
+	var out bytes.Buffer
+	write := func(v interface{}) { assert.NilError(t, binary.Write(&out, le, v)) }
+	attribute := func(name, kind string, value []byte) {
+		out.WriteString(name + "\x00" + kind + "\x00")
+		write(uint32(len(value)))
+		out.Write(value)
+	}
+
+	write(uint32(20000630))
+	write(uint32(2))
+
+	var channels bytes.Buffer
+	for _, channel := range []struct {
+		name      string
+		pixelType uint32
+	}{{"G", exrHalf}, {"R", exrFloat}} {
+		channels.WriteString(channel.name + "\x00")
+		assert.NilError(t, binary.Write(&channels, le, []uint32{channel.pixelType, 0, 1, 1}))
+	}
+	channels.WriteByte(0)
+	attribute("channels", "chlist", channels.Bytes())
+	attribute("compression", "compression", []byte{compression})
+	window := make([]byte, 16)
+	le.PutUint32(window[8:], uint32(width-1))
+	le.PutUint32(window[12:], uint32(height-1))
+	attribute("dataWindow", "box2i", window)
+	out.WriteByte(0)
+
+	linesPerBlock := 1
+	if compression == exrZIPCompression {
+		linesPerBlock = 16
+	}
+	blocks := (height + linesPerBlock - 1) / linesPerBlock
+	tableStart := out.Len()
+	out.Write(make([]byte, blocks*8))
+
+	for block := 0; block < blocks; block++ {
+		le.PutUint64(out.Bytes()[tableStart+block*8:], uint64(out.Len()))
+
+		var raw bytes.Buffer
+		for y := block * linesPerBlock; y < (block+1)*linesPerBlock && y < height; y++ {
+			for x := 0; x < width; x++ {
+				// 1.0 and 0.5 are exact in half precision
+				half := uint16(0x3C00)
+				if g[y*width+x] == 0.5 {
+					half = 0x3800
+				}
+				assert.NilError(t, binary.Write(&raw, le, half))
+			}
+			assert.NilError(t, binary.Write(&raw, le, r[y*width:(y+1)*width]))
+		}
+
+		data := raw.Bytes()
+		if compression == exrZIPCompression {
+			// Split the even and odd bytes, then store the differences
+			split := make([]byte, 0, len(data))
+			for i := 0; i < len(data); i += 2 {
+				split = append(split, data[i])
+			}
+			for i := 1; i < len(data); i += 2 {
+				split = append(split, data[i])
+			}
+			for i := len(split) - 1; i > 0; i-- {
+				split[i] = split[i] - split[i-1] + 128
+			}
+			var zipped bytes.Buffer
+			w := zlib.NewWriter(&zipped)
+			w.Write(split)
+			w.Close()
+			data = zipped.Bytes()
+		}
+		write(int32(block * linesPerBlock))
+		write(int32(len(data)))
+		out.Write(data)
+	}
+	return out.Bytes()
+}
+
+func TestDecodeOpenEXR(t *testing.T) {
+	r := []float32{0.25, 2, 3, 4, 5, 6}
+	g := []float32{1, 0.5, 1, 1, 0.5, 1}
+	for _, compression := range []byte{exrNoCompression, exrZIPCompression} {
+		data := encodeEXR(t, 2, 3, compression, r, g)
+		assert.Assert(t, isOpenEXR(data))
+		img, err := decodeOpenEXR(data)
+		assert.NilError(t, err)
+		assert.Equal(t, img.width, 2)
+		assert.Equal(t, img.height, 3)
+		assert.Equal(t, img.format().name, "RGBA32F")
+		// The bottom line comes first, missing blue and alpha default to 0 and 1
+		assert.DeepEqual(t, img.floats[:8], []float32{5, 0.5, 0, 1, 6, 1, 0, 1})
+		assert.DeepEqual(t, img.floats[16:], []float32{0.25, 1, 0, 1, 2, 0.5, 0, 1})
+	}
+
+	data := encodeEXR(t, 1, 1, 4, []float32{1}, []float32{1})
+	_, err := decodeOpenEXR(data)
+	assert.ErrorContains(t, err, "compression 4")
+}
+
+func TestHalfToFloat(t *testing.T) {
+	assert.Equal(t, halfToFloat(0x3C00), float32(1))
+	assert.Equal(t, halfToFloat(0xC000), float32(-2))
+	assert.Equal(t, halfToFloat(0x7BFF), float32(65504))
+	assert.Equal(t, halfToFloat(0x0001), float32(math.Pow(2, -24)))
+	assert.Assert(t, math.IsInf(float64(halfToFloat(0x7C00)), 1))
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	"github.com/inkyblackness/imgui-go"
+)
+
+// materialInstanceExtension is the extension of saved material instances
+const materialInstanceExtension = ".mati"
+
+// materialInstance draws with the shader and values of a parent material, except for the fields
+// it overrides. The parent program always holds the parent values: the overrides are uploaded
+// before each draw of the instance and the parent values are restored after it, so edits to the
+// parent reach every instance right away.
+type materialInstance struct {
+	name      string
+	parent    *material
+	overrides []materialField
+	// program is the parent program the overrides were located in
+	program uint32
+}
+
+// materialInstanceFile is the JSON layout of a .mati file. Only the overridden fields are stored.
+type materialInstanceFile struct {
+	Parent    string       `json:"parent"`
+	Overrides []fieldValue `json:"overrides"`
+}
+
+func newMaterialInstance(name string, parent *material) *materialInstance {
+	return &materialInstance{name: name, parent: parent, program: parent.shader.program}
+}
+
+// findUniform returns the material uniform declared with the given name
+func (s *shader) findUniform(name string) (uniform, bool) {
+	for _, u := range s.uniforms {
+		if u.name == name {
+			return u, true
+		}
+	}
+	return uniform{}, false
+}
+
+// findOverride returns the override of the named field, or nil if the parent value is used
+func (i *materialInstance) findOverride(name string) materialField {
+	for _, field := range i.overrides {
+		if field.fieldName() == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// setOverride overrides a parent field with the given value, or changes the value of an existing override
+func (i *materialInstance) setOverride(value fieldValue) error {
+	base := i.parent.findField(value.Name)
+	if base == nil {
+		return fmt.Errorf("material field %q doesn't exist in the shader", value.Name)
+	}
+	if base.fieldType() != value.Type {
+		return fmt.Errorf("material field %q is a %s, not a %s", value.Name, base.fieldType(), value.Type)
+	}
+
+	field := i.findOverride(value.Name)
+	if field == nil {
+		declaration, _ := i.parent.shader.findUniform(value.Name)
+		declaration.name, declaration.uType = value.Name, value.Type
+		field = newMaterialField(declaration)
+		field.locate(i.parent.uniforms)
+		i.overrides = append(i.overrides, field)
+	}
+	field.setValue(value)
+	return nil
+}
+
+// clearOverride makes the named field use the parent value again
+func (i *materialInstance) clearOverride(name string) {
+	for index, field := range i.overrides {
+		if field.fieldName() == name {
+			releaseField(field)
+			i.overrides = append(i.overrides[:index], i.overrides[index+1:]...)
+			return
+		}
+	}
+}
+
+// sync relocates the overrides after the parent switched programs, dropping the fields the new
+// shader no longer declares with the same type
+func (i *materialInstance) sync() {
+	if i.program == i.parent.shader.program {
+		return
+	}
+	i.program = i.parent.shader.program
+
+	kept := i.overrides[:0]
+	for _, field := range i.overrides {
+		base := i.parent.findField(field.fieldName())
+		if base == nil || base.fieldType() != field.fieldType() {
+			log.Printf("WARNING: instance %s drops its override of %q", i.name, field.fieldName())
+			releaseField(field)
+			continue
+		}
+		field.locate(i.parent.uniforms)
+		kept = append(kept, field)
+	}
+	i.overrides = kept
+}
+
+// loadTextures loads the textures of the overrides. Like the parent fields, a texture override is
+// loaded when it changes, so a missing file isn't read again on every draw.
+func (i *materialInstance) loadTextures() {
+	for _, field := range i.overrides {
+		loadTextureField(field, i.parent)
+	}
+}
+
+// loadTextureField loads the texture of a texture field and ignores other fields
+func loadTextureField(field materialField, mat *material) {
+	if t, ok := field.(*matFieldTexture); ok {
+		t.apply(mat)
+	}
+}
+
+// bind uploads the overrides and binds the textures of the instance. The parent program must be in use.
+func (i *materialInstance) bind() {
+	i.sync()
+	for _, field := range i.overrides {
+		if _, ok := field.(*matFieldTexture); !ok {
+			field.apply(i.parent)
+		}
+	}
+	bindTextureUnits(i.textureBindings())
+}
+
+// unbind uploads the parent values of the overridden fields again. The parent binds its own textures.
+func (i *materialInstance) unbind() {
+	for _, field := range i.overrides {
+		if _, ok := field.(*matFieldTexture); ok {
+			continue
+		}
+		if base := i.parent.findField(field.fieldName()); base != nil {
+			base.apply(i.parent)
+		}
+	}
+}
+
+// textureBindings lists the loaded textures in parent field order, taking overridden textures from the instance
+func (i *materialInstance) textureBindings() []textureBinding {
+	bindings := make([]textureBinding, 0)
+	for _, field := range i.parent.fields {
+		base, ok := field.(*matFieldTexture)
+		if !ok {
+			continue
+		}
+		t := base
+		if override, ok := i.findOverride(base.name).(*matFieldTexture); ok {
+			t = override
+		}
+		if t.tex.id != 0 {
+			bindings = append(bindings, textureBinding{glTexID: t.tex.id, uniformLocation: base.location, cube: base.cube})
+		}
+	}
+	return bindings
+}
+
+// release deletes the textures of the overrides. The parent is left alone.
+func (i *materialInstance) release() {
+	for _, field := range i.overrides {
+		releaseField(field)
+	}
+	i.overrides = nil
+}
+
+// releaseField deletes the texture a field holds, if any
+func releaseField(field materialField) {
+	if t, ok := field.(*matFieldTexture); ok {
+		t.tex.delete()
+	}
+}
+
+// drawUI draws a checkbox per parent field to override it, and the editor of every override
+func (i *materialInstance) drawUI() {
+	i.sync()
+	for _, base := range i.parent.fields {
+		name := base.fieldName()
+		imgui.PushID(name)
+
+		overridden := i.findOverride(name) != nil
+		if imgui.Checkbox("##override", &overridden) {
+			if overridden {
+				// Start from the current parent value
+				if err := i.setOverride(base.savedValue()); err != nil {
+					log.Printf("ERROR: %v", err)
+				} else {
+					loadTextureField(i.findOverride(name), i.parent)
+				}
+			} else {
+				i.clearOverride(name)
+			}
+		}
+		if imgui.IsItemHovered() {
+			imgui.SetTooltip("Override the parent value")
+		}
+		imgui.SameLine()
+
+		if field := i.findOverride(name); field != nil {
+			// Values are uploaded on the next draw of the instance, textures are loaded right away
+			if field.draw() {
+				loadTextureField(field, i.parent)
+			}
+		} else {
+			imgui.Text(name + " (parent)")
+		}
+		imgui.PopID()
+	}
+}
+
+// file returns the overrides of the instance as a diff against the parent material file
+func (i *materialInstance) file(parentPath string) materialInstanceFile {
+	file := materialInstanceFile{Parent: parentPath, Overrides: make([]fieldValue, 0, len(i.overrides))}
+	for _, field := range i.overrides {
+		file.Overrides = append(file.Overrides, field.savedValue())
+	}
+	return file
+}
+
+// save writes the instance to a .mati file. The parent has to be saved as a .mat file first.
+func (i *materialInstance) save(path string, parentPath string) error {
+	if parentPath == "" {
+		return fmt.Errorf("save the parent material before its instances")
+	}
+	bytes, err := json.MarshalIndent(i.file(parentPath), "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, bytes, 0644)
+}
+
+// restore sets the saved overrides. Overrides of fields the parent no longer has are skipped.
+func (i *materialInstance) restore(file materialInstanceFile) {
+	for _, value := range file.Overrides {
+		if err := i.setOverride(value); err != nil {
+			log.Printf("WARNING: instance %s: %v", i.name, err)
+		}
+	}
+}
+
+// readInstanceFile parses a .mati file
+func readInstanceFile(path string) (materialInstanceFile, error) {
+	var file materialInstanceFile
+
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return file, err
+	}
+	if err := json.Unmarshal(bytes, &file); err != nil {
+		return file, fmt.Errorf("%s: %v", path, err)
+	}
+	if file.Parent == "" {
+		return file, fmt.Errorf("%s: the parent material is missing", path)
+	}
+	return file, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func newTestParent() *material {
+	parent := &material{
+		shader: shader{program: 1, uniforms: getUniforms(`struct Material {
+			vec3 tint; // @color
+			float roughness; // @range(0,1) @default(0.5)
+			sampler2D albedo;
+			sampler2D mask;
+		};`)},
+		uniforms: &uniformTable{},
+	}
+	for _, u := range parent.shader.uniforms {
+		parent.fields = append(parent.fields, newMaterialField(u))
+	}
+	parent.fields[2].(*matFieldTexture).tex.id = 4
+	parent.fields[2].(*matFieldTexture).location = 2
+	parent.fields[3].(*matFieldTexture).tex.id = 5
+	parent.fields[3].(*matFieldTexture).location = 3
+	return parent
+}
+
+func TestMaterialInstanceOverrides(t *testing.T) {
+	parent := newTestParent()
+	instance := newMaterialInstance("red", parent)
+
+	assert.NilError(t, instance.setOverride(fieldValue{Name: "tint", Type: uniformVec3, Value: []float32{1, 0, 0}}))
+	assert.ErrorContains(t, instance.setOverride(fieldValue{Name: "missing", Type: uniformFloat}), "doesn't exist")
+	assert.ErrorContains(t, instance.setOverride(fieldValue{Name: "roughness", Type: uniformVec2}), "is a float")
+	assert.Assert(t, instance.findOverride("roughness") == nil)
+
+	// The override keeps the annotation of the declaration
+	tint := instance.findOverride("tint").(*matFieldVec3)
+	assert.Assert(t, tint.meta.color)
+
+	// Overridden textures replace the parent texture on the same unit
+	assert.NilError(t, instance.setOverride(fieldValue{Name: "mask", Type: uniformTex2D, Texture: "Assets/other.png"}))
+	instance.findOverride("mask").(*matFieldTexture).tex.id = 9
+	bindings := instance.textureBindings()
+	assert.Equal(t, len(bindings), 2)
+	assert.Equal(t, bindings[0], textureBinding{glTexID: 4, uniformLocation: 2})
+	assert.Equal(t, bindings[1], textureBinding{glTexID: 9, uniformLocation: 3})
+
+	// Only the overrides are saved
+	file := instance.file("Assets/base.mat")
+	assert.Equal(t, file.Parent, "Assets/base.mat")
+	assert.Equal(t, len(file.Overrides), 2)
+	assert.Equal(t, file.Overrides[0].Name, "tint")
+	assert.DeepEqual(t, file.Overrides[0].Value, []float32{1, 0, 0})
+	assert.Equal(t, file.Overrides[1].Texture, "Assets/other.png")
+
+	restored := newMaterialInstance("copy", parent)
+	restored.restore(file)
+	assert.Equal(t, len(restored.overrides), 2)
+	assert.DeepEqual(t, restored.findOverride("tint").savedValue().Value, []float32{1, 0, 0})
+
+	instance.clearOverride("mask")
+	assert.Equal(t, len(instance.overrides), 1)
+	assert.Equal(t, instance.textureBindings()[1], textureBinding{glTexID: 5, uniformLocation: 3})
+}
+
+func TestMaterialInstanceSync(t *testing.T) {
+	parent := newTestParent()
+	instance := newMaterialInstance("blue", parent)
+	assert.NilError(t, instance.setOverride(fieldValue{Name: "tint", Type: uniformVec3, Value: []float32{0, 0, 1}}))
+	assert.NilError(t, instance.setOverride(fieldValue{Name: "roughness", Type: uniformFloat, Value: []float32{0.1}}))
+
+	// A recompiled parent without roughness drops that override and keeps the other one
+	parent.shader.program = 2
+	parent.fields = parent.fields[:1]
+	instance.sync()
+	assert.Equal(t, instance.program, uint32(2))
+	assert.Equal(t, len(instance.overrides), 1)
+	assert.Equal(t, instance.overrides[0].fieldName(), "tint")
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-gl/gl/v3.2-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/inkyblackness/imgui-go"
+)
+
+type libraryEntryKind int
+
+const (
+	entryMaterial libraryEntryKind = iota
+	entryShaderPair
+	entryGLSLShader
+)
+
+// Tags every entry gets from its kind, so the kinds can be filtered like user tags
+var libraryKindTags = map[libraryEntryKind]string{
+	entryMaterial:   "material",
+	entryShaderPair: "shader",
+	entryGLSLShader: "glsl",
+}
+
+// libraryEntry is a material file or a shader found by the library scan
+type libraryEntry struct {
+	name string
+	kind libraryEntryKind
+	// path is the .mat, .glsl or .vert file of the entry
+	path     string
+	fragPath string
+	geomPath string
+	tags     []string
+
+	thumbnail        uint32
+	thumbnailPending bool
+}
+
+// scanLibrary lists the material files, vertex/fragment shader pairs and single-file shaders in the given directories
+func scanLibrary(dirs []string) ([]libraryEntry, error) {
+	entries := make([]libraryEntry, 0)
+
+	for _, dir := range dirs {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+
+			name := strings.TrimSuffix(info.Name(), filepath.Ext(path))
+			base := strings.TrimSuffix(path, filepath.Ext(path))
+			switch filepath.Ext(path) {
+			case materialFileExtension:
+				file, err := readMaterialFile(path)
+				if err != nil {
+					log.Printf("WARNING: skipping material: %v", err)
+					return nil
+				}
+				entries = append(entries, libraryEntry{name: name, kind: entryMaterial, path: path, tags: file.Tags})
+			case ".glsl":
+				entries = append(entries, libraryEntry{name: name, kind: entryGLSLShader, path: path})
+			case ".vert":
+				if !fileExists(base + ".frag") {
+					return nil
+				}
+				entry := libraryEntry{name: name, kind: entryShaderPair, path: path, fragPath: base + ".frag"}
+				if fileExists(base + ".geom") {
+					entry.geomPath = base + ".geom"
+				}
+				entries = append(entries, entry)
+			}
+			return nil
+		})
+		if err != nil {
+			return entries, err
+		}
+	}
+
+	for i := range entries {
+		entries[i].tags = append(entries[i].tags, libraryKindTags[entries[i].kind])
+		entries[i].thumbnailPending = true
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].name < entries[j].name
+	})
+	return entries, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// matches tells if the entry name contains the search text and the entry has every required tag
+func (e *libraryEntry) matches(search string, requiredTags map[string]bool) bool {
+	if !strings.Contains(strings.ToLower(e.name), strings.ToLower(search)) {
+		return false
+	}
+	for tag, required := range requiredTags {
+		if !required {
+			continue
+		}
+		found := false
+		for _, entryTag := range e.tags {
+			if entryTag == tag {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// splitCommaList splits a comma separated list of tags or directories
+func splitCommaList(text string) []string {
+	tags := make([]string, 0)
+	for _, tag := range strings.Split(text, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// loadEntryMaterial compiles the shader of an entry and returns a material with its saved or default values
+func loadEntryMaterial(entry *libraryEntry) (*material, error) {
+	if entry.kind == entryMaterial {
+		return loadMaterial(entry.path)
+	}
+
+	var s shader
+	var err error
+	if entry.kind == entryGLSLShader {
+		err = s.loadFromGLSLFile(entry.path)
+	} else {
+		err = s.loadFromFile(entry.path, entry.fragPath, entry.geomPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	mat := new(material)
+	mat.init(s)
+	mat.applyUniforms()
+	return mat, nil
+}
+
+const thumbnailSize = 96
+
+// thumbnailRenderer renders materials on a sphere into offscreen textures
+type thumbnailRenderer struct {
+	fbo      uint32
+	depth    uint32
+	verts    []float32
+	renderer renderer
+}
+
+// render draws the material into a new texture and returns it
+func (tr *thumbnailRenderer) render(mat *material) (uint32, error) {
+	if tr.fbo == 0 {
+		gl.GenFramebuffers(1, &tr.fbo)
+		gl.GenRenderbuffers(1, &tr.depth)
+		gl.BindRenderbuffer(gl.RENDERBUFFER, tr.depth)
+		gl.RenderbufferStorage(gl.RENDERBUFFER, gl.DEPTH_COMPONENT24, thumbnailSize, thumbnailSize)
+		gl.BindRenderbuffer(gl.RENDERBUFFER, 0)
+		tr.renderer.setData(tr.verts, mat)
+	}
+
+	var tex uint32
+	gl.GenTextures(1, &tex)
+	gl.BindTexture(gl.TEXTURE_2D, tex)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, thumbnailSize, thumbnailSize, 0, gl.RGBA, gl.UNSIGNED_BYTE, nil)
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, tr.fbo)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, tex, 0)
+	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.RENDERBUFFER, tr.depth)
+	if status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER); status != gl.FRAMEBUFFER_COMPLETE {
+		gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+		gl.DeleteTextures(1, &tex)
+		return 0, fmt.Errorf("thumbnail framebuffer incomplete: 0x%x", status)
+	}
+
+	var lastViewport [4]int32
+	var lastClearColor [4]float32
+	gl.GetIntegerv(gl.VIEWPORT, &lastViewport[0])
+	gl.GetFloatv(gl.COLOR_CLEAR_VALUE, &lastClearColor[0])
+	gl.Viewport(0, 0, thumbnailSize, thumbnailSize)
+	gl.ClearColor(0.2, 0.2, 0.2, 1)
+	// A material without depth writes leaves the mask off, which would keep the depth buffer from clearing
+	gl.DepthMask(true)
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+
+	projection := mgl32.Perspective(mgl32.DegToRad(45.0), 1, GlobalRenderProps.CameraNear, GlobalRenderProps.CameraFar)
+	view := mgl32.LookAtV(mgl32.Vec3{0, 0, 3}, mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 1, 0})
+
+	for _, pass := range mat.allPasses() {
+		ApplyGlobalRenderProperties(pass.shader.program)
+	}
+	tr.renderer.material = mat
+	tr.renderer.issueDrawCall(mgl32.Ident4(), view, projection)
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	gl.Viewport(lastViewport[0], lastViewport[1], lastViewport[2], lastViewport[3])
+	gl.ClearColor(lastClearColor[0], lastClearColor[1], lastClearColor[2], lastClearColor[3])
+	return tex, nil
+}
+
+// materialLibrary is the browser panel listing the materials and shaders found in the configured directories
+type materialLibrary struct {
+	dirs       string
+	search     string
+	tagFilter  map[string]bool
+	entries    []libraryEntry
+	scanned    bool
+	scanError  error
+	thumbnails thumbnailRenderer
+}
+
+// rescan lists the directories again and drops the old thumbnails
+func (l *materialLibrary) rescan() {
+	for _, entry := range l.entries {
+		if entry.thumbnail != 0 {
+			gl.DeleteTextures(1, &entry.thumbnail)
+		}
+	}
+
+	dirs := splitCommaList(l.dirs)
+	l.entries, l.scanError = scanLibrary(dirs)
+	l.scanned = true
+	if l.tagFilter == nil {
+		l.tagFilter = make(map[string]bool)
+	}
+}
+
+// renderNextThumbnail renders one pending thumbnail, spreading the compile cost over several frames
+func (l *materialLibrary) renderNextThumbnail() {
+	for i := range l.entries {
+		entry := &l.entries[i]
+		if !entry.thumbnailPending {
+			continue
+		}
+		entry.thumbnailPending = false
+
+		mat, err := loadEntryMaterial(entry)
+		if err != nil {
+			log.Printf("WARNING: no thumbnail for %s: %v", entry.name, err)
+			return
+		}
+		entry.thumbnail, err = l.thumbnails.render(mat)
+		if err != nil {
+			log.Printf("WARNING: no thumbnail for %s: %v", entry.name, err)
+		}
+		mat.release()
+		return
+	}
+}
+
+// allTags returns the tags of every entry, sorted
+func (l *materialLibrary) allTags() []string {
+	tags := make(map[string]bool)
+	for _, entry := range l.entries {
+		for _, tag := range entry.tags {
+			tags[tag] = true
+		}
+	}
+	sorted := make([]string, 0, len(tags))
+	for tag := range tags {
+		sorted = append(sorted, tag)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// drawUI draws the library panel and returns the entry the user clicked, or nil
+func (l *materialLibrary) drawUI() *libraryEntry {
+	if !l.scanned {
+		l.rescan()
+	}
+	l.renderNextThumbnail()
+
+	imgui.Text("Directories")
+	imgui.SameLine()
+	imgui.InputText("##libraryDirs", &l.dirs)
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Comma separated list of directories to scan")
+	}
+	imgui.SameLine()
+	if imgui.Button("Rescan") {
+		l.rescan()
+	}
+	if l.scanError != nil {
+		imgui.Text("ERROR: " + l.scanError.Error())
+	}
+
+	imgui.Text("Search")
+	imgui.SameLine()
+	imgui.InputText("##librarySearch", &l.search)
+
+	imgui.Text("Tags")
+	for _, tag := range l.allTags() {
+		imgui.SameLine()
+		enabled := l.tagFilter[tag]
+		if imgui.Checkbox(tag+"##tagFilter", &enabled) {
+			l.tagFilter[tag] = enabled
+		}
+	}
+	imgui.Separator()
+
+	var clicked *libraryEntry
+	for i := range l.entries {
+		entry := &l.entries[i]
+		if !entry.matches(l.search, l.tagFilter) {
+			continue
+		}
+
+		imgui.PushID(entry.path)
+		// The framebuffer texture is stored bottom up
+		imgui.ImageV(imgui.TextureID(entry.thumbnail), imgui.Vec2{X: thumbnailSize, Y: thumbnailSize},
+			imgui.Vec2{X: 0, Y: 1}, imgui.Vec2{X: 1, Y: 0}, imgui.Vec4{X: 1, Y: 1, Z: 1, W: 1}, imgui.Vec4{})
+		if imgui.IsItemClicked(0) {
+			clicked = entry
+		}
+		imgui.SameLine()
+		if imgui.Selectable(entry.name) {
+			clicked = entry
+		}
+		if imgui.IsItemHovered() {
+			imgui.SetTooltip(entry.path + "\n" + strings.Join(entry.tags, ", "))
+		}
+		imgui.PopID()
+	}
+	return clicked
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func findEntry(entries []libraryEntry, name string) *libraryEntry {
+	for i := range entries {
+		if entries[i].name == name {
+			return &entries[i]
+		}
+	}
+	return nil
+}
+
+func TestScanLibrary(t *testing.T) {
+	entries, err := scanLibrary([]string{"Assets"})
+	assert.NilError(t, err)
+
+	mat := findEntry(entries, "cellShade")
+	assert.Assert(t, mat != nil)
+	assert.Equal(t, mat.kind, entryMaterial)
+	assert.Equal(t, mat.path, "Assets/cellShade.mat")
+
+	pair := findEntry(entries, "cellShadeColor")
+	assert.Assert(t, pair != nil)
+	assert.Equal(t, pair.kind, entryShaderPair)
+	assert.Equal(t, pair.fragPath, "Assets/cellShadeColor.frag")
+	assert.Equal(t, pair.geomPath, "")
+
+	normals := findEntry(entries, "normals")
+	assert.Assert(t, normals != nil)
+	assert.Equal(t, normals.geomPath, "Assets/normals.geom")
+
+	glsl := findEntry(entries, "blinnPhongColor")
+	assert.Assert(t, glsl != nil)
+	assert.Equal(t, glsl.kind, entryGLSLShader)
+}
+
+func TestLibraryEntryMatches(t *testing.T) {
+	entry := libraryEntry{name: "cellShade", tags: []string{"toon", "material"}}
+
+	assert.Assert(t, entry.matches("", nil))
+	assert.Assert(t, entry.matches("SHADE", nil))
+	assert.Assert(t, !entry.matches("phong", nil))
+	assert.Assert(t, entry.matches("", map[string]bool{"toon": true, "glsl": false}))
+	assert.Assert(t, !entry.matches("", map[string]bool{"toon": true, "glsl": true}))
+
+	assert.DeepEqual(t, splitCommaList(" toon, ,metal "), []string{"toon", "metal"})
+}
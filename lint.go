@@ -0,0 +1,480 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-gl/gl/v3.2-core/gl"
+)
+
+// The linter checks the interface between shader stages without a GL context:
+// varyings, material structs, universal uniforms and vertex attributes.
+
+type lintSeverity string
+
+const (
+	lintError   lintSeverity = "error"
+	lintWarning lintSeverity = "warning"
+)
+
+type lintIssue struct {
+	file     string
+	line     int
+	severity lintSeverity
+	message  string
+}
+
+func (i lintIssue) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s", i.file, i.line, i.severity, i.message)
+}
+
+// glslDeclaration is a global in, out or uniform declaration, or a field of the Material struct
+type glslDeclaration struct {
+	qualifier string
+	glslType  string
+	name      string
+	line      int
+	block     string
+}
+
+// lintStage is the source of a single stage together with the file it was read from
+type lintStage struct {
+	file         string
+	shaderType   uint32
+	declarations []glslDeclaration
+}
+
+// attributeTypes holds the GLSL types of the vertex attributes renderer.setData binds
+var attributeTypes = map[string]string{
+	vertAttribName:     "vec3",
+	texCoordAttribName: "vec2",
+	normalAttribName:   "vec3",
+}
+
+var interpolationQualifiers = map[string]bool{
+	"flat":          true,
+	"smooth":        true,
+	"noperspective": true,
+	"centroid":      true,
+}
+
+// runLint lints every shader in the given directory and returns the process exit code. The default is
+// the Assets of the package, or of the working directory when the package isn't in the GOPATH.
+func runLint(args []string) int {
+	dir := "Assets"
+	if len(args) > 0 {
+		dir = args[0]
+	} else if packageDir, err := importPathToDir("GoGL"); err == nil {
+		dir = filepath.Join(packageDir, "Assets")
+	}
+
+	issues, err := lintDirectory(dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	errors := 0
+	for _, issue := range issues {
+		fmt.Println(issue)
+		if issue.severity == lintError {
+			errors++
+		}
+	}
+	fmt.Printf("%d issues, %d errors\n", len(issues), errors)
+
+	if errors > 0 {
+		return 1
+	}
+	return 0
+}
+
+// lintDirectory lints every .vert/.frag pair (with an optional .geom) and every .glsl file in dir
+func lintDirectory(dir string) ([]lintIssue, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	issues := make([]lintIssue, 0)
+	for _, file := range files {
+		path := filepath.Join(dir, file.Name())
+		switch filepath.Ext(file.Name()) {
+		case ".vert":
+			base := strings.TrimSuffix(path, ".vert")
+			fragPath := base + ".frag"
+			if _, err := os.Stat(fragPath); err != nil {
+				issues = append(issues, lintIssue{path, 0, lintWarning, "no matching fragment shader " + fragPath})
+				continue
+			}
+			geomPath := base + ".geom"
+			if _, err := os.Stat(geomPath); err != nil {
+				geomPath = ""
+			}
+
+			fileIssues, err := lintShaderFiles(path, fragPath, geomPath)
+			if err != nil {
+				return nil, err
+			}
+			issues = append(issues, fileIssues...)
+		case ".glsl":
+			fileIssues, err := lintGLSLFile(path)
+			if err != nil {
+				return nil, err
+			}
+			issues = append(issues, fileIssues...)
+		}
+	}
+	return issues, nil
+}
+
+// lintShaderFiles lints a vertex and fragment shader pair. Pass an empty geomPath if there is no geometry stage.
+func lintShaderFiles(vertPath string, fragPath string, geomPath string) ([]lintIssue, error) {
+	paths := []string{vertPath, geomPath, fragPath}
+	shaderTypes := []uint32{gl.VERTEX_SHADER, gl.GEOMETRY_SHADER, gl.FRAGMENT_SHADER}
+
+	stages := make([]lintStage, 0, 3)
+	for i, path := range paths {
+		if path == "" {
+			continue
+		}
+		source, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, lintStage{path, shaderTypes[i], parseDeclarations(string(source))})
+	}
+
+	return lintStages(stages), nil
+}
+
+// lintGLSLFile splits a single-file shader into its stages and lints them
+func lintGLSLFile(path string) ([]lintIssue, error) {
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	stageSources, err := splitStages(string(source))
+	if err != nil {
+		return []lintIssue{{path, 0, lintError, err.Error()}}, nil
+	}
+
+	stages := make([]lintStage, 0, 3)
+	for _, shaderType := range []uint32{gl.VERTEX_SHADER, gl.GEOMETRY_SHADER, gl.FRAGMENT_SHADER} {
+		if stageSource, ok := stageSources[shaderType]; ok {
+			stages = append(stages, lintStage{path, shaderType, parseDeclarations(stageSource)})
+		}
+	}
+
+	return lintStages(stages), nil
+}
+
+// lintStages checks the stages, given in pipeline order
+func lintStages(stages []lintStage) []lintIssue {
+	issues := make([]lintIssue, 0)
+
+	for i, stage := range stages {
+		if stage.shaderType == gl.VERTEX_SHADER {
+			issues = append(issues, lintAttributes(stage)...)
+		}
+		issues = append(issues, lintUniforms(stage)...)
+
+		if i > 0 {
+			issues = append(issues, lintVaryings(stages[i-1], stage)...)
+		}
+	}
+
+	issues = append(issues, lintMaterialStructs(stages)...)
+	return issues
+}
+
+// lintAttributes checks that every vertex input is an attribute the renderer provides
+func lintAttributes(stage lintStage) []lintIssue {
+	issues := make([]lintIssue, 0)
+	for _, decl := range stage.declarations {
+		if decl.qualifier != "in" {
+			continue
+		}
+
+		expectedType, ok := attributeTypes[decl.name]
+		if !ok {
+			issues = append(issues, lintIssue{stage.file, decl.line, lintError,
+				fmt.Sprintf("vertex input %q is not provided by the renderer (expected one of %s)", decl.name, strings.Join(sortedKeys(attributeTypes), ", "))})
+		} else if decl.glslType != expectedType {
+			issues = append(issues, lintIssue{stage.file, decl.line, lintError,
+				fmt.Sprintf("vertex input %q is declared %s but the renderer provides %s", decl.name, decl.glslType, expectedType)})
+		}
+	}
+	return issues
+}
+
+// lintUniforms checks the uniforms outside the Material struct against the universal uniforms set by the engine
+func lintUniforms(stage lintStage) []lintIssue {
+	issues := make([]lintIssue, 0)
+	globalsMember := 0
+	for _, decl := range stage.declarations {
+		if decl.qualifier != "uniform" || decl.glslType == "Material" || decl.glslType == "material" {
+			continue
+		}
+
+		if decl.block == globalsBlockName {
+			issues = append(issues, lintGlobalsMember(stage, decl, globalsMember)...)
+			globalsMember++
+			continue
+		}
+
+		builtin, ok := findBuiltinUniform(decl.name)
+		if !ok {
+			issues = append(issues, lintIssue{stage.file, decl.line, lintWarning,
+				fmt.Sprintf("uniform %q is not set by the engine, declare it in the Material struct to edit it", decl.name)})
+		} else if decl.glslType != string(builtin.uType) {
+			issues = append(issues, lintIssue{stage.file, decl.line, lintError,
+				fmt.Sprintf("universal uniform %q is declared %s but the engine sets a %s", decl.name, decl.glslType, builtin.uType)})
+		}
+	}
+
+	if globalsMember > 0 && globalsMember < len(globalsBlockMembers) {
+		issues = append(issues, lintIssue{stage.file, 0, lintError,
+			fmt.Sprintf("%s block declares %d members but the engine packs %d", globalsBlockName, globalsMember, len(globalsBlockMembers))})
+	}
+	return issues
+}
+
+// lintGlobalsMember checks a member of the Globals block against the packed buffer layout
+func lintGlobalsMember(stage lintStage, decl glslDeclaration, index int) []lintIssue {
+	if index >= len(globalsBlockMembers) {
+		return []lintIssue{{stage.file, decl.line, lintError,
+			fmt.Sprintf("%s block member %q is not packed by the engine", globalsBlockName, decl.name)}}
+	}
+
+	expected, _ := findBuiltinUniform(globalsBlockMembers[index])
+	if decl.name != expected.name || decl.glslType != string(expected.uType) {
+		return []lintIssue{{stage.file, decl.line, lintError,
+			fmt.Sprintf("%s block member %d is \"%s %s\" but the engine packs \"%s %s\"", globalsBlockName, index, decl.glslType, decl.name, expected.uType, expected.name)}}
+	}
+	return nil
+}
+
+// lintVaryings checks the outputs of a stage against the inputs of the next stage
+func lintVaryings(producer lintStage, consumer lintStage) []lintIssue {
+	issues := make([]lintIssue, 0)
+
+	outputs := make(map[string]glslDeclaration)
+	for _, decl := range producer.declarations {
+		if decl.qualifier == "out" {
+			outputs[decl.name] = decl
+		}
+	}
+
+	inputs := make(map[string]bool)
+	for _, decl := range consumer.declarations {
+		if decl.qualifier != "in" {
+			continue
+		}
+		inputs[decl.name] = true
+
+		output, ok := outputs[decl.name]
+		if !ok {
+			issues = append(issues, lintIssue{consumer.file, decl.line, lintError,
+				fmt.Sprintf("%s input %q is not written by the %s stage", stageName(consumer.shaderType), decl.name, stageName(producer.shaderType))})
+		} else if output.glslType != decl.glslType {
+			issues = append(issues, lintIssue{consumer.file, decl.line, lintError,
+				fmt.Sprintf("%s input %q is declared %s but the %s stage writes a %s", stageName(consumer.shaderType), decl.name, decl.glslType, stageName(producer.shaderType), output.glslType)})
+		}
+	}
+
+	for _, decl := range producer.declarations {
+		if decl.qualifier == "out" && !inputs[decl.name] {
+			issues = append(issues, lintIssue{producer.file, decl.line, lintWarning,
+				fmt.Sprintf("%s output %q is not read by the %s stage", stageName(producer.shaderType), decl.name, stageName(consumer.shaderType))})
+		}
+	}
+
+	return issues
+}
+
+// lintMaterialStructs checks that every stage declaring the Material struct declares the same fields
+func lintMaterialStructs(stages []lintStage) []lintIssue {
+	issues := make([]lintIssue, 0)
+
+	var reference *lintStage
+	var referenceFields []glslDeclaration
+	for i := range stages {
+		fields := materialFields(stages[i].declarations)
+		if fields == nil {
+			continue
+		}
+		if reference == nil {
+			reference = &stages[i]
+			referenceFields = fields
+			continue
+		}
+
+		for j, field := range fields {
+			if j >= len(referenceFields) {
+				issues = append(issues, lintIssue{stages[i].file, field.line, lintError,
+					fmt.Sprintf("material field %q is not declared in the %s stage", field.name, stageName(reference.shaderType))})
+				continue
+			}
+			expected := referenceFields[j]
+			if expected.name != field.name || expected.glslType != field.glslType {
+				issues = append(issues, lintIssue{stages[i].file, field.line, lintError,
+					fmt.Sprintf("material field %d is \"%s %s\" but \"%s %s\" in the %s stage", j, field.glslType, field.name, expected.glslType, expected.name, stageName(reference.shaderType))})
+			}
+		}
+		for j := len(fields); j < len(referenceFields); j++ {
+			issues = append(issues, lintIssue{stages[i].file, fields[len(fields)-1].line, lintError,
+				fmt.Sprintf("material field %q of the %s stage is missing", referenceFields[j].name, stageName(reference.shaderType))})
+		}
+	}
+
+	return issues
+}
+
+// materialFields returns the fields of the Material struct, or nil if the stage does not declare it
+func materialFields(declarations []glslDeclaration) []glslDeclaration {
+	var fields []glslDeclaration
+	for _, decl := range declarations {
+		if decl.qualifier == "material" {
+			fields = append(fields, decl)
+		}
+	}
+	return fields
+}
+
+// parseDeclarations returns the global in, out and uniform declarations, the members of uniform blocks
+// and the Material struct fields of a stage source
+func parseDeclarations(source string) []glslDeclaration {
+	declarations := make([]glslDeclaration, 0)
+
+	depth := 0
+	inMaterial := false
+	block := ""
+	for i, line := range strings.Split(stripComments(source), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if depth == 0 {
+			if strings.HasPrefix(trimmed, "struct Material") || strings.HasPrefix(trimmed, "struct material") {
+				inMaterial = true
+			} else if blockName, ok := parseBlockStart(trimmed); ok {
+				block = blockName
+			} else if decl, ok := parseDeclaration(trimmed); ok {
+				decl.line = i + 1
+				declarations = append(declarations, decl)
+			}
+		} else if depth == 1 && (inMaterial || block != "") {
+			if decl, ok := parseMember(trimmed); ok {
+				decl.line = i + 1
+				decl.block = block
+				if inMaterial {
+					decl.qualifier = "material"
+				}
+				declarations = append(declarations, decl)
+			}
+		}
+
+		depth += strings.Count(line, "{") - strings.Count(line, "}")
+		if depth == 0 {
+			inMaterial = false
+			block = ""
+		}
+	}
+
+	return declarations
+}
+
+// declarationWords splits a declaration into words, without the layout and interpolation qualifiers
+func declarationWords(line string) []string {
+	if strings.HasPrefix(line, "layout") {
+		if end := strings.Index(line, ")"); end >= 0 {
+			line = line[end+1:]
+		}
+	}
+
+	words := strings.Fields(strings.Replace(line, ";", " ", -1))
+	for len(words) > 0 && interpolationQualifiers[words[0]] {
+		words = words[1:]
+	}
+	return words
+}
+
+// parseDeclaration parses a single declaration line such as "layout(location = 0) flat in vec3 normal[];"
+func parseDeclaration(line string) (glslDeclaration, bool) {
+	words := declarationWords(line)
+	if len(words) < 3 || (words[0] != "in" && words[0] != "out" && words[0] != "uniform") {
+		return glslDeclaration{}, false
+	}
+	return glslDeclaration{qualifier: words[0], glslType: words[1], name: trimArray(words[2])}, true
+}
+
+// parseMember parses a struct or uniform block member such as "vec3 color;"
+func parseMember(line string) (glslDeclaration, bool) {
+	words := declarationWords(line)
+	if len(words) < 2 || words[0] == "}" {
+		return glslDeclaration{}, false
+	}
+	return glslDeclaration{qualifier: "uniform", glslType: words[0], name: trimArray(words[1])}, true
+}
+
+// parseBlockStart returns the name of a uniform block started on the line, such as "layout(std140) uniform Globals {"
+func parseBlockStart(line string) (string, bool) {
+	words := declarationWords(line)
+	if len(words) >= 2 && words[0] == "uniform" && (len(words) == 2 || words[2] == "{" || strings.HasPrefix(words[2], "{")) {
+		return strings.TrimSuffix(words[1], "{"), true
+	}
+	return "", false
+}
+
+// stripComments blanks line and block comments while keeping the line structure intact
+func stripComments(source string) string {
+	var result strings.Builder
+	inBlock := false
+	for i := 0; i < len(source); i++ {
+		switch {
+		case inBlock && strings.HasPrefix(source[i:], "*/"):
+			inBlock = false
+			i++
+		case inBlock:
+			if source[i] == '\n' {
+				result.WriteByte('\n')
+			}
+		case strings.HasPrefix(source[i:], "/*"):
+			inBlock = true
+			i++
+		case strings.HasPrefix(source[i:], "//"):
+			for i < len(source) && source[i] != '\n' {
+				i++
+			}
+			if i < len(source) {
+				result.WriteByte('\n')
+			}
+		default:
+			result.WriteByte(source[i])
+		}
+	}
+	return result.String()
+}
+
+func trimArray(name string) string {
+	if bracket := strings.Index(name, "["); bracket >= 0 {
+		return name[:bracket]
+	}
+	return name
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/go-gl/gl/v3.2-core/gl"
+	"gotest.tools/assert"
+)
+
+func TestParseDeclarations(t *testing.T) {
+
+	testShader := `#version 330
+	struct Material {
+		vec3 color; // @color
+		float specPower;
+	};
+	uniform Material material;
+	uniform mat4 modelMatrix; /* block
+	comment */ layout(location = 0) flat in vec3 normal;
+	out vec3 fragNormal[];
+	layout(std140) uniform Globals {
+		float time;
+		vec3 lightDir;
+	};
+	void main() {
+		vec3 local = normal;
+	}`
+
+	declarations := parseDeclarations(testShader)
+	expected := []glslDeclaration{
+		{"material", "vec3", "color", 3, ""},
+		{"material", "float", "specPower", 4, ""},
+		{"uniform", "Material", "material", 6, ""},
+		{"uniform", "mat4", "modelMatrix", 7, ""},
+		{"in", "vec3", "normal", 8, ""},
+		{"out", "vec3", "fragNormal", 9, ""},
+		{"uniform", "float", "time", 11, "Globals"},
+		{"uniform", "vec3", "lightDir", 12, "Globals"},
+	}
+	assert.Equal(t, len(declarations), len(expected), "Invalid number of declarations parsed.")
+	for i := range expected {
+		assert.Equal(t, declarations[i], expected[i])
+	}
+}
+
+func TestLintStages(t *testing.T) {
+
+	vert := lintStage{"test.vert", gl.VERTEX_SHADER, parseDeclarations(`#version 330
+	struct Material {
+		vec3 color;
+	};
+	uniform mat3 modelMatrix;
+	in vec3 vert;
+	in vec4 normal;
+	in vec3 tangent;
+	out vec3 fragNormal;
+	out vec2 fragTexCoord;`)}
+
+	frag := lintStage{"test.frag", gl.FRAGMENT_SHADER, parseDeclarations(`#version 330
+	struct Material {
+		vec4 color;
+	};
+	uniform vec3 cameraPos;
+	in vec3 fragNormal;
+	in vec3 fragWorldPos;
+	out vec4 outputColor;`)}
+
+	issues := lintStages([]lintStage{vert, frag})
+
+	expected := []lintIssue{
+		{"test.vert", 7, lintError, `vertex input "normal" is declared vec4 but the renderer provides vec3`},
+		{"test.vert", 8, lintError, `vertex input "tangent" is not provided by the renderer (expected one of normal, vert, vertTexCoord)`},
+		{"test.vert", 5, lintError, `universal uniform "modelMatrix" is declared mat3 but the engine sets a mat4`},
+		{"test.frag", 5, lintWarning, `uniform "cameraPos" is not set by the engine, declare it in the Material struct to edit it`},
+		{"test.frag", 7, lintError, `fragment input "fragWorldPos" is not written by the vertex stage`},
+		{"test.vert", 10, lintWarning, `vertex output "fragTexCoord" is not read by the fragment stage`},
+		{"test.frag", 3, lintError, `material field 0 is "vec4 color" but "vec3 color" in the vertex stage`},
+	}
+	assert.Equal(t, len(issues), len(expected), "Invalid number of lint issues.")
+	for i := range expected {
+		assert.Equal(t, issues[i], expected[i])
+	}
+}
+
+func TestLintAssets(t *testing.T) {
+
+	issues, err := lintDirectory("Assets")
+	assert.NilError(t, err)
+
+	for _, issue := range issues {
+		assert.Assert(t, issue.severity != lintError, issue.String())
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/go-gl/gl/v3.2-core/gl"
+)
+
+// uniformTable caches the uniform locations of a linked program, so drawing doesn't have to
+// call gl.GetUniformLocation with freshly allocated C strings
+type uniformTable struct {
+	locations map[string]int32
+	// builtins are the registered built-ins the program uses, with their locations
+	builtins []boundBuiltin
+}
+
+type boundBuiltin struct {
+	builtin  builtinUniform
+	location int32
+}
+
+// uniformTables holds the table of every linked program, keyed by program name
+var uniformTables = make(map[uint32]*uniformTable)
+
+// newUniformTable queries the active uniforms of a linked program. Members of uniform blocks
+// have no location and are left out.
+func newUniformTable(program uint32) *uniformTable {
+	table := &uniformTable{locations: make(map[string]int32)}
+
+	var count, maxLength int32
+	gl.GetProgramiv(program, gl.ACTIVE_UNIFORMS, &count)
+	gl.GetProgramiv(program, gl.ACTIVE_UNIFORM_MAX_LENGTH, &maxLength)
+
+	nameBuffer := make([]uint8, maxLength+1)
+	for i := uint32(0); i < uint32(count); i++ {
+		var length, size int32
+		var xtype uint32
+		gl.GetActiveUniform(program, i, int32(len(nameBuffer)), &length, &size, &xtype, &nameBuffer[0])
+		name := string(nameBuffer[:length])
+
+		location := gl.GetUniformLocation(program, gl.Str(name+"\x00"))
+		if location < 0 {
+			continue
+		}
+		table.locations[name] = location
+
+		// Arrays are reported as "name[0]", make them reachable by their plain name too
+		if strings.HasSuffix(name, "[0]") {
+			table.locations[strings.TrimSuffix(name, "[0]")] = location
+		}
+	}
+
+	for _, builtin := range builtinUniforms {
+		if location, ok := table.locations[builtin.name]; ok {
+			table.builtins = append(table.builtins, boundBuiltin{builtin, location})
+		}
+	}
+
+	return table
+}
+
+// lookupUniformTable returns the cached table of a program, building it if the program wasn't linked by newProgram
+func lookupUniformTable(program uint32) *uniformTable {
+	table, ok := uniformTables[program]
+	if !ok {
+		table = newUniformTable(program)
+		uniformTables[program] = table
+	}
+	return table
+}
+
+// location returns the cached location of a uniform, or -1 if the program doesn't use it
+func (t *uniformTable) location(name string) int32 {
+	if location, ok := t.locations[name]; ok {
+		return location
+	}
+	return -1
+}
+
+// applyBuiltins uploads every built-in of the given scope to the program currently in use
+func (t *uniformTable) applyBuiltins(scope builtinScope, ctx *objectContext) {
+	// A material whose shader never compiled has no table
+	if t == nil {
+		return
+	}
+	for _, bound := range t.builtins {
+		if bound.builtin.scope == scope {
+			setUniformValue(bound.location, bound.builtin.provide(ctx))
+		}
+	}
+}
@@ -3,10 +3,14 @@ package main
 import (
 	"fmt"
 	"go/build"
+	_ "image/gif"
+	_ "image/jpeg"
 	_ "image/png"
 	"log"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strings"
 
 	"GoGL/gui"
 	"GoGL/platform"
@@ -33,12 +37,34 @@ type state struct {
 	clearColorR    float32
 	clearColorG    float32
 	clearColorB    float32
+	glslSource     string
 	vertSource     string
 	fragSource     string
+	geomSource     string
 	activeMaterial material
 	activeModel    []float32
 	modelRenderer  renderer
 	shaderError    error
+	shadertoy      shadertoy
+	editor         shaderEditor
+	materialPath   string
+	materialError  error
+	library        materialLibrary
+	// recompileReport lists the fields added, removed or retyped by the last compile
+	recompileReport string
+	// instances are drawn next to the model, each with its own overrides of the active material
+	instances        []*materialInstance
+	selectedInstance int
+	instancePath     string
+	instanceError    error
+	// The shader pair of the next pass added to the active material
+	passVertSource string
+	passFragSource string
+	passError      error
+	timeline       timeline
+	// activeModelName names the model in the history entries
+	activeModelName string
+	skybox          skybox
 }
 
 type data struct {
@@ -54,6 +80,14 @@ var reApplyUniformsa = false
 func init() {
 	// GLFW event handling must run on the main OS thread
 	runtime.LockOSThread()
+}
+
+func main() {
+	// "GoGL lint [dir]" checks the shader interfaces without opening a window. It runs in the working
+	// directory of the caller and doesn't need the package in the GOPATH.
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		os.Exit(runLint(os.Args[2:]))
+	}
 
 	// Set the working directory to the root of Go package, so that its assets can be accessed.
 	dir, err := importPathToDir("GoGL")
@@ -64,9 +98,7 @@ func init() {
 	if err != nil {
 		log.Panicln("os.Chdir:", err)
 	}
-}
 
-func main() {
 	context, imguiInput := gui.NewImgui()
 	defer context.Destroy()
 
@@ -99,11 +131,8 @@ func main() {
 	state := new(state)
 	data := new(data)
 
-	var defaultShader shader
-	defaultShader.loadFromFile("Assets/simpleGreen.vert", "Assets/simpleGreen.frag")
-
 	// Set up projection matrix for shader
-	projection := mgl32.Perspective(mgl32.DegToRad(45.0), float32(windowWidth)/windowHeight, 0.1, 10.0)
+	projection := mgl32.Perspective(mgl32.DegToRad(45.0), float32(windowWidth)/windowHeight, GlobalRenderProps.CameraNear, GlobalRenderProps.CameraFar)
 
 	// Set up view matrix for shader
 	cameraPos := mgl32.Vec3{0, 2, 3}
@@ -132,14 +161,20 @@ func main() {
 	data.coneVerts = coneModel.ToArrayXYZUVN1N2N3()
 
 	// Setup initial state
-	state.activeMaterial.init(defaultShader)
 	state.activeModel = data.boxVerts
-	state.modelRenderer.setData(state.activeModel, state.activeMaterial)
-	state.vertSource = ""
-	state.fragSource = ""
+	state.activeModelName = "Box"
+	state.modelRenderer.setData(state.activeModel, &state.activeMaterial)
+	state.glslSource = ""
+	state.vertSource = "Assets/simpleGreen.vert"
+	state.fragSource = "Assets/simpleGreen.frag"
+	state.geomSource = ""
+	compileActiveShader(state)
 	state.clearColorR = 1
 	state.clearColorG = 1
 	state.clearColorB = 1
+	state.shadertoy.source = defaultShadertoySource
+	state.library.dirs = "Assets"
+	state.library.thumbnails.verts = data.sphereVerts
 	state.rotationSpeed = float32(0.5)
 	state.scale = float32(1.0)
 
@@ -157,31 +192,71 @@ func main() {
 			imgui.Begin("Material Viewer")
 
 			drawShaderInputGUI(state)
+			drawMaterialFileGUI(state)
 
 			// Draw the material GUI
 			if len(state.activeMaterial.fields) != 0 || len(state.activeMaterial.texBindings) != 0 {
 				imgui.Text("		")
 				imgui.Text("Shader Properties")
 				state.modelRenderer.material.drawUI()
-				if imgui.ButtonV("Apply", imgui.Vec2{X: 100, Y: 30}) {
-					state.modelRenderer.material.applyUniforms()
-				}
+			}
+			if state.activeMaterial.shader.program != 0 {
+				imgui.Text("Render State")
+				state.activeMaterial.renderState.drawUI()
+				drawPassesGUI(state)
 			}
 
 			imgui.End()
 			imgui.Begin("Global Properties")
 			drawUtilityGUI(state, data)
 			imgui.End()
+			imgui.Begin("Built-in Uniforms")
+			if state.shadertoy.enabled {
+				drawBuiltinsGUI(&state.shadertoy.material.shader)
+			} else {
+				drawBuiltinsGUI(&state.activeMaterial.shader)
+			}
+			imgui.End()
+			imgui.Begin("Shadertoy")
+			state.shadertoy.drawUI()
+			imgui.End()
+			imgui.Begin("Material Library")
+			if entry := state.library.drawUI(); entry != nil {
+				loadLibraryEntry(state, entry)
+			}
+			imgui.End()
+			imgui.Begin("Assets")
+			drawAssetsGUI()
+			imgui.End()
+			imgui.Begin("Instances")
+			drawInstancesGUI(state)
+			imgui.End()
+			imgui.Begin("Timeline")
+			state.timeline.drawUI(&state.activeMaterial)
+			imgui.End()
+			imgui.Begin("Shader Editor")
+			if state.editor.drawUI() {
+				compileActiveShader(state)
+			}
+			imgui.End()
+			imgui.Begin("History")
+			undoHistory.drawUI()
+			imgui.End()
+
+			undoHistory.handleShortcuts()
+			// A drag is recorded as one entry: the edits merge until the mouse is released
+			if !imgui.IsMouseDown(0) {
+				undoHistory.seal()
+			}
 		}
 
 		// Rendering
 		imgui.Render() // This call only creates the draw data list. Actual rendering to framebuffer is done below.
 
-		// Need to reanable these things since Nuklear sets its own gl states when rendering.
+		// The materials set their render state before each draw, but a material without depth writes
+		// would keep the depth buffer from being cleared
+		gl.DepthMask(true)
 		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
-		gl.Enable(gl.DEPTH_TEST)
-		gl.Enable(gl.CULL_FACE)
-		gl.DepthFunc(gl.LESS)
 		gl.ClearColor(state.clearColorR,
 			state.clearColorG,
 			state.clearColorB,
@@ -196,14 +271,67 @@ func main() {
 
 		model = mgl32.HomogRotate3D(float32(angle), mgl32.Vec3{0, 1, 0})
 		model = model.Mul4(mgl32.Scale3D(state.scale, state.scale, state.scale))
+		objectCount := len(state.instances) + 1
 
 		// Set global rendering properties
 		GlobalRenderProps.CameraPos = [3]float32{cameraPos.X(), cameraPos.Y(), cameraPos.Z()}
 		GlobalRenderProps.Time = float32(time)
-		ApplyGlobalRenderProperties(state.activeMaterial.shader.program)
+		GlobalRenderProps.DeltaTime = float32(elapsed)
+		GlobalRenderProps.FrameIndex++
+		GlobalRenderProps.Resolution = platform.FramebufferSize()
+
+		// Mouse position in framebuffer pixels with the origin in the lower left corner, like gl_FragCoord
+		displaySize := platform.DisplaySize()
+		if displaySize[0] > 0 && displaySize[1] > 0 {
+			GlobalRenderProps.Mouse = [2]float32{
+				float32(cursorX) * GlobalRenderProps.Resolution[0] / displaySize[0],
+				(displaySize[1] - float32(cursorY)) * GlobalRenderProps.Resolution[1] / displaySize[1]}
+		}
+
+		// Track left button drags outside the GUI windows
+		if mouseState.MousePress[0] && (GlobalRenderProps.MouseDown || !imgui.CurrentIO().WantCaptureMouse()) {
+			if !GlobalRenderProps.MouseDown {
+				GlobalRenderProps.MouseClick = GlobalRenderProps.Mouse
+			}
+			GlobalRenderProps.MouseDrag = GlobalRenderProps.Mouse
+			GlobalRenderProps.MouseDown = true
+		} else {
+			GlobalRenderProps.MouseDown = false
+		}
+
+		// Keyframed values are set before the globals are uploaded, expressions read the final globals
+		state.timeline.update(float32(elapsed), &state.activeMaterial)
+		exprEnv := builtinExprEnv()
+		for _, pass := range state.activeMaterial.allPasses() {
+			pass.updateExpressions(exprEnv)
+		}
 
-		// Render the model
-		state.modelRenderer.issueDrawCall(model, view, projection)
+		UpdateGlobalsBuffer()
+		textureAssets.reloadChanged()
+
+		if state.shadertoy.enabled && state.shadertoy.material.shader.program != 0 {
+			// Render the fullscreen Shadertoy pass instead of the model
+			state.shadertoy.draw()
+		} else {
+			if state.skybox.enabled {
+				// The sky replaces the clear color behind the models
+				state.skybox.draw(view, projection)
+			}
+			for _, pass := range state.activeMaterial.allPasses() {
+				ApplyGlobalRenderProperties(pass.shader.program)
+			}
+
+			// Render the model with the active material, then once per instance next to it
+			for i := 0; i < objectCount; i++ {
+				state.modelRenderer.instance = nil
+				if i > 0 {
+					state.modelRenderer.instance = state.instances[i-1]
+				}
+				offset := objectOffset(i, objectCount, 2.5*state.scale)
+				state.modelRenderer.issueDrawCall(mgl32.Translate3D(offset, 0, 0).Mul4(model), view, projection)
+			}
+			state.modelRenderer.instance = nil
+		}
 
 		// Maintenance
 		imguiRenderer.Render(platform.DisplaySize(), platform.FramebufferSize(), imgui.RenderedDrawData())
@@ -213,84 +341,360 @@ func main() {
 
 func drawShaderInputGUI(state *state) {
 	imgui.Text("Shader Programs")
+	imgui.Text("glsl source")
+	imgui.SameLine()
+	imgui.InputText("##glsl source", &state.glslSource)
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Single-file shader with #pragma stage sections. Used instead of the separate stages when set")
+	}
 	imgui.Text("vert source")
 	imgui.SameLine()
 	imgui.InputText("##vert source", &state.vertSource)
 	imgui.Text("frag source")
 	imgui.SameLine()
 	imgui.InputText("##frag source", &state.fragSource)
+	imgui.Text("geom source")
+	imgui.SameLine()
+	imgui.InputText("##geom source", &state.geomSource)
+	if imgui.IsItemHovered() {
+		imgui.SetTooltip("Optional geometry shader, leave empty to skip the stage")
+	}
 
 	if imgui.ButtonV("Compile", imgui.Vec2{X: 100, Y: 30}) {
-		var newShader shader
-		state.shaderError = newShader.loadFromFile(state.vertSource, state.fragSource)
-		if state.shaderError == nil {
-			var newMaterial material
-			newMaterial.init(newShader)
-			state.activeMaterial = newMaterial
-			state.modelRenderer.setData(state.activeModel, state.activeMaterial)
-		} else {
-			log.Printf("ERROR: " + (state.shaderError).Error())
-		}
+		compileActiveShader(state)
 	}
 
 	if state.shaderError != nil {
 		err := state.shaderError.Error()
 		imgui.InputTextMultiline("##shaderError", &err)
+	} else if state.recompileReport != "" {
+		imgui.Text(state.recompileReport)
+	}
+
+}
+
+// compileActiveShader builds the shader from the source paths, replaces the active material
+// on success and opens the sources in the editor
+func compileActiveShader(state *state) {
+	before := state.activeMaterial.file()
+	var newShader shader
+	if state.glslSource != "" {
+		state.shaderError = newShader.loadFromGLSLFile(state.glslSource)
+	} else {
+		state.shaderError = newShader.loadFromFile(state.vertSource, state.fragSource, state.geomSource)
+	}
+	openSourcesInEditor(state)
+	if state.shaderError != nil {
+		log.Printf("ERROR: " + (state.shaderError).Error())
+		return
+	}
+
+	// Keep the tuned values of the fields that survived the edit
+	var newMaterial material
+	newMaterial.init(newShader)
+	if state.activeMaterial.shader.program != 0 {
+		changes := newMaterial.carryOver(&state.activeMaterial)
+		state.recompileReport = changes.String()
+		log.Printf("Recompiled, %s", state.recompileReport)
+	}
+	newMaterial.activate()
+
+	state.activeMaterial.release()
+	state.activeMaterial = newMaterial
+	state.modelRenderer.setData(state.activeModel, &state.activeMaterial)
+	recordShaderSwap(state, before)
+}
+
+// recordShaderSwap adds a history entry if the active material uses other shader files than before
+func recordShaderSwap(state *state, before materialFile) {
+	after := state.activeMaterial.file()
+	if (before.GLSL == "" && before.Fragment == "") || sameShader(before, after) {
+		return
+	}
+	undoHistory.push(&shaderCommand{state: state, before: before, after: after})
+}
+
+// restoreShader compiles the shader of a material file and restores its values, to undo or redo a shader swap
+func restoreShader(state *state, file materialFile) {
+	state.glslSource, state.vertSource, state.fragSource, state.geomSource = file.GLSL, file.Vertex, file.Fragment, file.Geometry
+	compileActiveShader(state)
+	if state.shaderError == nil {
+		state.activeMaterial.restore(file)
+	}
+}
+
+// openSourcesInEditor shows the files of the source path fields in the shader editor
+func openSourcesInEditor(state *state) {
+	if state.glslSource != "" {
+		state.editor.open([]string{"glsl"}, []*string{&state.glslSource})
+	} else {
+		state.editor.open([]string{"vert", "frag", "geom"}, []*string{&state.vertSource, &state.fragSource, &state.geomSource})
+	}
+}
+
+// drawMaterialFileGUI draws the Save and Load buttons for .mat files
+func drawMaterialFileGUI(state *state) {
+	imgui.Text("material file")
+	imgui.SameLine()
+	imgui.InputText("##material file", &state.materialPath)
+
+	tags := strings.Join(state.activeMaterial.tags, ", ")
+	imgui.Text("tags")
+	imgui.SameLine()
+	if imgui.InputText("##material tags", &tags) {
+		state.activeMaterial.tags = splitCommaList(tags)
 	}
 
+	if imgui.ButtonV("Save", imgui.Vec2{X: 100, Y: 30}) {
+		if !strings.HasSuffix(state.materialPath, materialFileExtension) {
+			state.materialPath += materialFileExtension
+		}
+		state.materialError = state.activeMaterial.save(state.materialPath)
+	}
+	imgui.SameLine()
+	if imgui.ButtonV("Load", imgui.Vec2{X: 100, Y: 30}) {
+		loadActiveMaterial(state, state.materialPath)
+	}
+
+	if state.materialError != nil {
+		imgui.Text("ERROR: " + state.materialError.Error())
+	}
+}
+
+// drawPassesGUI draws the fields and render state of every extra pass of the active material
+// and the inputs to add a pass
+func drawPassesGUI(state *state) {
+	imgui.Text("		")
+	imgui.Text("Passes")
+	mat := &state.activeMaterial
+	for i, pass := range mat.passes {
+		label := fmt.Sprintf("Pass %d: %s", i+2, filepath.Base(pass.shader.fragPath))
+		if !imgui.CollapsingHeader(fmt.Sprintf("%s##pass%d", label, i)) {
+			continue
+		}
+
+		imgui.PushID(fmt.Sprintf("pass%d", i))
+		mat.drawPassUI(i)
+		imgui.Text("Render State")
+		pass.renderState.drawUI()
+		remove := imgui.Button("Remove pass")
+		imgui.PopID()
+
+		if remove {
+			mat.removePass(i)
+			break
+		}
+	}
+
+	imgui.Text("pass vert")
+	imgui.SameLine()
+	imgui.InputText("##pass vert", &state.passVertSource)
+	imgui.Text("pass frag")
+	imgui.SameLine()
+	imgui.InputText("##pass frag", &state.passFragSource)
+	if imgui.Button("Add pass") {
+		var pass *material
+		pass, state.passError = loadPass(state.passVertSource, state.passFragSource)
+		if state.passError == nil {
+			mat.passes = append(mat.passes, pass)
+		}
+	}
+	if state.passError != nil {
+		imgui.Text("ERROR: " + state.passError.Error())
+	}
+}
+
+// loadActiveMaterial replaces the active material with a saved one and opens its shader in the editor
+func loadActiveMaterial(state *state, path string) {
+	before := state.activeMaterial.file()
+	mat, err := loadMaterial(path)
+	state.materialError = err
+	if err != nil {
+		log.Printf("ERROR: " + err.Error())
+		return
+	}
+
+	state.activeMaterial.release()
+	state.activeMaterial = *mat
+	state.modelRenderer.setData(state.activeModel, &state.activeMaterial)
+	state.shaderError = nil
+
+	s := &state.activeMaterial.shader
+	state.glslSource, state.vertSource, state.fragSource, state.geomSource = s.glslPath, s.vertPath, s.fragPath, s.geomPath
+	openSourcesInEditor(state)
+	recordShaderSwap(state, before)
+}
+
+// loadLibraryEntry puts the material or shader picked in the library on the current model
+func loadLibraryEntry(state *state, entry *libraryEntry) {
+	switch entry.kind {
+	case entryMaterial:
+		state.materialPath = entry.path
+		loadActiveMaterial(state, entry.path)
+	case entryGLSLShader:
+		state.glslSource = entry.path
+		compileActiveShader(state)
+	case entryShaderPair:
+		state.glslSource, state.vertSource, state.fragSource, state.geomSource = "", entry.path, entry.fragPath, entry.geomPath
+		compileActiveShader(state)
+	}
+}
+
+// objectOffset returns the x position of an object in a row of objects centered on the origin
+func objectOffset(index int, count int, spacing float32) float32 {
+	return (float32(index) - float32(count-1)/2) * spacing
+}
+
+// drawInstancesGUI lists the instances of the active material and draws the overrides of the selected one
+func drawInstancesGUI(state *state) {
+	if imgui.Button("Add instance") {
+		name := fmt.Sprintf("instance %d", len(state.instances)+1)
+		state.instances = append(state.instances, newMaterialInstance(name, &state.activeMaterial))
+		state.selectedInstance = len(state.instances) - 1
+	}
+
+	imgui.Text("instance file")
+	imgui.SameLine()
+	imgui.InputText("##instance file", &state.instancePath)
+	if imgui.ButtonV("Load", imgui.Vec2{X: 100, Y: 30}) {
+		loadInstance(state, state.instancePath)
+	}
+	if state.instanceError != nil {
+		imgui.Text("ERROR: " + state.instanceError.Error())
+	}
+	imgui.Separator()
+
+	for i, instance := range state.instances {
+		if imgui.SelectableV(instance.name, i == state.selectedInstance, 0, imgui.Vec2{}) {
+			state.selectedInstance = i
+		}
+	}
+	if state.selectedInstance >= len(state.instances) {
+		return
+	}
+	instance := state.instances[state.selectedInstance]
+	imgui.Separator()
+
+	if imgui.ButtonV("Save", imgui.Vec2{X: 100, Y: 30}) {
+		if !strings.HasSuffix(state.instancePath, materialInstanceExtension) {
+			state.instancePath += materialInstanceExtension
+		}
+		state.instanceError = instance.save(state.instancePath, state.materialPath)
+	}
+	imgui.SameLine()
+	if imgui.ButtonV("Remove", imgui.Vec2{X: 100, Y: 30}) {
+		instance.release()
+		state.instances = append(state.instances[:state.selectedInstance], state.instances[state.selectedInstance+1:]...)
+		state.selectedInstance = 0
+		return
+	}
+
+	instance.drawUI()
+}
+
+// loadInstance adds a saved instance, loading its parent as the active material if another one is active
+func loadInstance(state *state, path string) {
+	file, err := readInstanceFile(path)
+	state.instanceError = err
+	if err != nil {
+		log.Printf("ERROR: " + err.Error())
+		return
+	}
+
+	if file.Parent != state.materialPath {
+		state.materialPath = file.Parent
+		loadActiveMaterial(state, file.Parent)
+		if state.materialError != nil {
+			state.instanceError = state.materialError
+			return
+		}
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), materialInstanceExtension)
+	instance := newMaterialInstance(name, &state.activeMaterial)
+	instance.restore(file)
+	instance.loadTextures()
+	state.instances = append(state.instances, instance)
+	state.selectedInstance = len(state.instances) - 1
+}
+
+// setActiveModel shows the given model with the active material
+func setActiveModel(state *state, name string, verts []float32) {
+	state.activeModel = verts
+	state.activeModelName = name
+	state.modelRenderer.setData(state.activeModel, &state.activeMaterial)
+	state.modelRenderer.material.applyUniforms()
+}
+
+// switchModel shows another model and records the switch in the history
+func switchModel(state *state, name string, verts []float32) {
+	if name == state.activeModelName {
+		return
+	}
+	undoHistory.push(&modelCommand{state: state,
+		beforeName: state.activeModelName, beforeVerts: state.activeModel,
+		afterName: name, afterVerts: verts})
+	setActiveModel(state, name, verts)
 }
 
 // Draw the utility functions GUI.
 func drawUtilityGUI(state *state, data *data) {
 	imgui.Columns(4, "")
 	if imgui.Button("	Sphere	") {
-		state.activeModel = data.sphereVerts
-		state.modelRenderer.setData(state.activeModel, state.activeMaterial)
-		state.modelRenderer.material.applyUniforms()
+		switchModel(state, "Sphere", data.sphereVerts)
 	}
 	imgui.NextColumn()
 	if imgui.Button("	Box		") {
-		state.activeModel = data.boxVerts
-		state.modelRenderer.setData(state.activeModel, state.activeMaterial)
-		state.modelRenderer.material.applyUniforms()
+		switchModel(state, "Box", data.boxVerts)
 	}
 	imgui.NextColumn()
 	if imgui.Button("	Torus	") {
-		state.activeModel = data.torusVerts
-		state.modelRenderer.setData(state.activeModel, state.activeMaterial)
-		state.modelRenderer.material.applyUniforms()
+		switchModel(state, "Torus", data.torusVerts)
 	}
 	imgui.NextColumn()
 	if imgui.Button("	Plane	") {
-		state.activeModel = data.planeVerts
-		state.modelRenderer.setData(state.activeModel, state.activeMaterial)
-		state.modelRenderer.material.applyUniforms()
+		switchModel(state, "Plane", data.planeVerts)
 	}
 	imgui.Columns(1, "")
 
 	imgui.Columns(4, "")
 	imgui.Text("Clear color:")
 	imgui.NextColumn()
-	imgui.SliderFloat("R", &state.clearColorR, 0, 1)
+	clearColor := []*float32{&state.clearColorR, &state.clearColorG, &state.clearColorB}
+	before := readFloats(clearColor)
+	changed := imgui.SliderFloat("R", &state.clearColorR, 0, 1)
 	imgui.NextColumn()
-	imgui.SliderFloat("G", &state.clearColorG, 0, 1)
+	changed = imgui.SliderFloat("G", &state.clearColorG, 0, 1) || changed
 	imgui.NextColumn()
-	imgui.SliderFloat("B", &state.clearColorB, 0, 1)
+	changed = imgui.SliderFloat("B", &state.clearColorB, 0, 1) || changed
 	imgui.Columns(1, "")
+	if changed {
+		undoHistory.push(newValueCommand("clear color", clearColor, before))
+	}
+	state.skybox.drawUI()
+
 	imgui.Text("Rotation speed:")
 	imgui.SameLine()
-	imgui.SliderFloat("##rotSpeed", &state.rotationSpeed, 0, 10)
+	before = []float32{state.rotationSpeed}
+	if imgui.SliderFloat("##rotSpeed", &state.rotationSpeed, 0, 10) {
+		undoHistory.push(newValueCommand("rotation speed", []*float32{&state.rotationSpeed}, before))
+	}
 
+	// The light is uploaded every frame, so undoing only has to restore the values
 	imgui.Text("LightDir")
 	imgui.SameLine()
+	before = append([]float32(nil), GlobalRenderProps.LightDir[:]...)
 	if imgui.SliderFloat3("##lightDir", &GlobalRenderProps.LightDir, -365, 365) {
 		ApplyLightColor(state.activeMaterial.shader.program)
+		undoHistory.push(newValueCommand("light direction", floatPointers(GlobalRenderProps.LightDir[:]), before))
 	}
 
 	imgui.Text("LightColor")
 	imgui.SameLine()
+	before = append([]float32(nil), GlobalRenderProps.LightColor[:]...)
 	if imgui.SliderFloat3("##lightCol", &GlobalRenderProps.LightColor, 0, 1) {
 		ApplyLightColor(state.activeMaterial.shader.program)
+		undoHistory.push(newValueCommand("light color", floatPointers(GlobalRenderProps.LightColor[:]), before))
 	}
 
 }
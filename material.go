@@ -2,197 +2,1011 @@ package main
 
 import (
 	"fmt"
+	"log"
+	"strings"
 
 	"github.com/go-gl/gl/v3.2-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
 	"github.com/inkyblackness/imgui-go"
 )
 
-var texUnit int32
-
 type material struct {
-	shader      shader
-	fields      []materialField
-	texBindings []textureBinding
+	shader          shader
+	uniforms        *uniformTable
+	fields          []materialField
+	texBindings     []textureBinding
+	enabledKeywords map[string]bool
+	variantError    error
+	renderState     renderState
+	// tags are saved with the material to filter the library
+	tags []string
+	// passes are drawn after the material itself, in order, each with its own shader, fields and render state
+	passes []*material
+	// animation keyframes the fields of the material and the global properties over time
+	animation animation
+	// expressions drive field values instead of constants, keyed by field name
+	expressions map[string]*fieldExpression
+	// expressionField is the field picked in the editor to get a new expression
+	expressionField string
 }
 
 type textureBinding struct {
 	glTexID         uint32
 	uniformLocation int32
+	// cube binds the texture as a cubemap
+	cube bool
 }
 
 type materialField interface {
-	draw()
+	fieldName() string
+	fieldType() uniformType
+	// savedValue returns the serializable value of the field
+	savedValue() fieldValue
+	// setValue restores a serialized value of the same type
+	setValue(value fieldValue)
+	// draw draws the field editor and returns true when the value changed
+	draw() bool
+	// locate caches the uniform location of the field in the given program table
+	locate(table *uniformTable)
 	apply(mat *material)
 }
 
 // Material functions
 func (m *material) init(shader shader) {
 	m.shader = shader
+	m.enabledKeywords = make(map[string]bool)
+	m.renderState = shader.renderState
+	m.animation.Length = defaultAnimationLength
 
 	for _, uniform := range shader.uniforms {
-		switch uniform.uType {
-		case uniformFloat:
-			m.fields = append(m.fields, &matFieldFloat{uniform.name, 0})
-		case uniformVec2:
-			m.fields = append(m.fields, &matFieldVec2{uniform.name, 0, 0})
-		case uniformVec3:
-			m.fields = append(m.fields, &matFieldVec3{uniform.name, 1, 0, 0})
-		case uniformVec4:
-			m.fields = append(m.fields, &matFieldVec4{uniform.name, 1, 0, 0, 0})
-		case uniformTex2D:
-			tex := texture{}
-			m.fields = append(m.fields, &matFieldTexture{uniform.name, tex, ""})
+		if field := newMaterialField(uniform); field != nil {
+			m.fields = append(m.fields, field)
 		}
+	}
+	m.locateFields()
+}
 
+// locateFields resolves the uniform locations of the fields in the current program
+func (m *material) locateFields() {
+	m.uniforms = lookupUniformTable(m.shader.program)
+	for _, field := range m.fields {
+		field.locate(m.uniforms)
 	}
 }
 
+// newMaterialField creates the editor field of a shader uniform with its annotated default value,
+// or returns nil for unsupported types
+func newMaterialField(uniform uniform) materialField {
+	meta := uniform.annotation
+	switch uniform.uType {
+	case uniformFloat:
+		value := defaultFieldValue(meta, []float32{0})
+		return &matFieldFloat{name: uniform.name, value: value[0], meta: meta}
+	case uniformVec2:
+		value := defaultFieldValue(meta, []float32{0, 0})
+		return &matFieldVec2{name: uniform.name, x: value[0], y: value[1], meta: meta}
+	case uniformVec3:
+		value := defaultFieldValue(meta, []float32{1, 0, 0})
+		return &matFieldVec3{name: uniform.name, x: value[0], y: value[1], z: value[2], meta: meta}
+	case uniformVec4:
+		value := defaultFieldValue(meta, []float32{1, 0, 0, 0})
+		return &matFieldVec4{name: uniform.name, x: value[0], y: value[1], z: value[2], w: value[3], meta: meta}
+	case uniformTex2D, uniformTexCube:
+		return &matFieldTexture{name: uniform.name, sampler: defaultSamplerSettings(), meta: meta, cube: uniform.uType == uniformTexCube}
+	case uniformInt, uniformIVec2, uniformIVec3, uniformIVec4:
+		field := &matFieldInt{name: uniform.name, uType: uniform.uType, meta: meta}
+		field.setValue(fieldValue{Value: defaultFieldValue(meta, make([]float32, intComponents(uniform.uType)))})
+		return field
+	case uniformBool:
+		value := defaultFieldValue(meta, []float32{0})
+		return &matFieldBool{name: uniform.name, value: value[0] != 0, meta: meta}
+	case uniformMat3, uniformMat4:
+		field := &matFieldMatrix{name: uniform.name, uType: uniform.uType, scale: [3]float32{1, 1, 1}, meta: meta}
+		// Matrices default to identity unless the whole translate/rotate/scale composition is given
+		if len(meta.defaultValue) == 9 {
+			field.setValue(fieldValue{Value: meta.defaultValue})
+		}
+		return field
+	}
+	return nil
+}
+
+// defaultFieldValue returns the annotated default value of a field, falling back to the range minimum
+// and then to the given fallback. A single default value is used for every component.
+func defaultFieldValue(meta uniformAnnotation, fallback []float32) []float32 {
+	value := make([]float32, len(fallback))
+	copy(value, fallback)
+
+	switch {
+	case len(meta.defaultValue) == 1:
+		for i := range value {
+			value[i] = meta.defaultValue[0]
+		}
+	case len(meta.defaultValue) > 1:
+		copy(value, meta.defaultValue)
+	case meta.hasRange:
+		for i := range value {
+			value[i] = meta.min
+		}
+	}
+
+	return value
+}
+
+// drawUI draws the field editors. Changed fields are uploaded right away.
 func (m *material) drawUI() {
+	m.drawFieldsUI(m, -1)
+}
+
+// drawPassUI draws the field editors of the extra pass at the given index
+func (m *material) drawPassUI(index int) {
+	m.passes[index].drawFieldsUI(m, index)
+}
+
+// drawFieldsUI draws the field editors and records the edits as fields of the given pass of root
+func (m *material) drawFieldsUI(root *material, pass int) {
+	m.drawKeywordsUI()
+
 	for _, field := range m.fields {
-		field.draw()
+		before := field.savedValue()
+		if t, ok := field.(*matFieldTexture); ok {
+			// The path is typed in place, the last applied one is in the texture
+			before.Texture = t.tex.filePath
+		}
+		if field.draw() {
+			gl.UseProgram(m.shader.program)
+			field.apply(m)
+			m.rebuildTexBindings()
+			undoHistory.push(&fieldCommand{root: root, pass: pass, before: before, after: field.savedValue()})
+		}
 	}
+
+	m.drawExpressionsUI()
 }
 
+// drawKeywordsUI draws a checkbox per shader keyword and switches to the matching program variant on toggle
+func (m *material) drawKeywordsUI() {
+	if len(m.shader.keywords) == 0 {
+		return
+	}
+
+	imgui.Text("Keywords")
+	changed := false
+	for _, keyword := range m.shader.keywords {
+		enabled := m.enabledKeywords[keyword]
+		if imgui.Checkbox(keyword, &enabled) {
+			m.enabledKeywords[keyword] = enabled
+			changed = true
+		}
+	}
+
+	if changed {
+		m.variantError = m.setVariant(m.activeKeywords())
+	}
+
+	if m.variantError != nil {
+		err := m.variantError.Error()
+		imgui.InputTextMultiline("##variantError", &err)
+	}
+}
+
+// activeKeywords returns the enabled keywords in declaration order
+func (m *material) activeKeywords() []string {
+	keywords := make([]string, 0)
+	for _, keyword := range m.shader.keywords {
+		if m.enabledKeywords[keyword] {
+			keywords = append(keywords, keyword)
+		}
+	}
+	return keywords
+}
+
+// setVariant switches the material to the program compiled with the given keywords and re-applies its uniforms
+func (m *material) setVariant(keywords []string) error {
+	program, err := m.shader.variant(keywords)
+	if err != nil {
+		log.Printf("ERROR: " + err.Error())
+		return err
+	}
+
+	m.shader.program = program
+	m.locateFields()
+	m.applyUniforms()
+	return nil
+}
+
+// activate switches to the variant of the enabled keywords, if any, and uploads the uniforms
+func (m *material) activate() {
+	if len(m.activeKeywords()) != 0 {
+		m.variantError = m.setVariant(m.activeKeywords())
+		return
+	}
+	m.applyUniforms()
+}
+
+// fieldChanges lists how the fields of a recompiled shader differ from the previous material
+type fieldChanges struct {
+	added   []string
+	removed []string
+	retyped []string
+}
+
+func (c fieldChanges) empty() bool {
+	return len(c.added) == 0 && len(c.removed) == 0 && len(c.retyped) == 0
+}
+
+func (c fieldChanges) String() string {
+	if c.empty() {
+		return "fields unchanged"
+	}
+	parts := make([]string, 0, 3)
+	if len(c.added) != 0 {
+		parts = append(parts, "added: "+strings.Join(c.added, ", "))
+	}
+	if len(c.removed) != 0 {
+		parts = append(parts, "removed: "+strings.Join(c.removed, ", "))
+	}
+	if len(c.retyped) != 0 {
+		parts = append(parts, "retyped: "+strings.Join(c.retyped, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// carryOver copies the values of the fields whose name and type still match from the previous
+// material, along with the keywords that still exist, the render state, the tags, the animation and the passes
+func (m *material) carryOver(previous *material) fieldChanges {
+	var changes fieldChanges
+	for _, field := range m.fields {
+		old := previous.findField(field.fieldName())
+		switch {
+		case old == nil:
+			changes.added = append(changes.added, field.fieldName())
+		case old.fieldType() != field.fieldType():
+			changes.retyped = append(changes.retyped, fmt.Sprintf("%s (%s to %s)", field.fieldName(), old.fieldType(), field.fieldType()))
+		default:
+			field.setValue(old.savedValue())
+			if expression, ok := previous.expressions[field.fieldName()]; ok {
+				m.setExpression(field.fieldName(), expression.source, builtinExprEnv())
+			}
+		}
+	}
+	for _, old := range previous.fields {
+		if m.findField(old.fieldName()) == nil {
+			changes.removed = append(changes.removed, old.fieldName())
+		}
+	}
+
+	for _, keyword := range m.shader.keywords {
+		m.enabledKeywords[keyword] = previous.enabledKeywords[keyword]
+	}
+	// Render state edited in the GUI survives unless the pragmas changed
+	if m.shader.renderState == previous.shader.renderState {
+		m.renderState = previous.renderState
+	}
+	m.tags = previous.tags
+	m.animation = previous.animation
+
+	// The passes move over, so releasing the previous material keeps them
+	m.passes = previous.passes
+	previous.passes = nil
+	return changes
+}
+
+// applyUniforms uploads every field to the current program
 func (m *material) applyUniforms() {
-	texUnit = 0
-	// Clear texturebinding list
+	gl.UseProgram(m.shader.program)
 	for _, field := range m.fields {
 		field.apply(m)
 	}
+	m.rebuildTexBindings()
+}
+
+// rebuildTexBindings lists the loaded textures of the texture fields in field order
+func (m *material) rebuildTexBindings() {
+	m.texBindings = m.texBindings[:0]
+	for _, field := range m.fields {
+		if t, ok := field.(*matFieldTexture); ok && t.tex.id != 0 {
+			m.texBindings = append(m.texBindings, textureBinding{glTexID: t.tex.id, uniformLocation: t.location, cube: t.cube})
+		}
+	}
+}
+
+// release deletes the textures and programs of the material
+func (m *material) release() {
+	for _, field := range m.fields {
+		releaseField(field)
+	}
+	m.texBindings = nil
+	m.shader.delete()
+
+	for i := len(m.passes) - 1; i >= 0; i-- {
+		m.passes[i].release()
+		undoHistory.forgetPass(m, i)
+	}
+	m.passes = nil
+}
+
+// setExpression makes the named field follow an expression of the per frame built-in variables
+func (m *material) setExpression(name string, source string, env exprEnv) error {
+	field := m.findField(name)
+	if field == nil {
+		return fmt.Errorf("material field %q doesn't exist in the shader", name)
+	}
+	if !animatable(field) {
+		return fmt.Errorf("material field %q is a %s and can't be driven by an expression", name, field.fieldType())
+	}
+
+	expression, err := newFieldExpression(source, len(field.savedValue().Value), env)
+	if err != nil {
+		return err
+	}
+	if m.expressions == nil {
+		m.expressions = make(map[string]*fieldExpression)
+	}
+	m.expressions[name] = expression
+	return nil
+}
+
+// evaluateExpressions sets the fields driven by expressions and returns true if there were any
+func (m *material) evaluateExpressions(env exprEnv) bool {
+	evaluated := false
+	for _, field := range m.fields {
+		expression, ok := m.expressions[field.fieldName()]
+		if !ok {
+			continue
+		}
+		value := expression.eval(len(field.savedValue().Value), env)
+		field.setValue(fieldValue{Name: field.fieldName(), Type: field.fieldType(), Value: value})
+		evaluated = true
+	}
+	return evaluated
+}
+
+// updateExpressions evaluates the expressions of the frame and uploads the fields they drive
+func (m *material) updateExpressions(env exprEnv) {
+	if !m.evaluateExpressions(env) {
+		return
+	}
+	gl.UseProgram(m.shader.program)
+	for _, field := range m.fields {
+		if _, ok := m.expressions[field.fieldName()]; ok {
+			field.apply(m)
+		}
+	}
+}
+
+// drawExpressionsUI draws the expression of every driven field and the inputs to add one
+func (m *material) drawExpressionsUI() {
+	targets := make([]string, 0)
+	for _, field := range m.fields {
+		if _, ok := m.expressions[field.fieldName()]; !ok && animatable(field) {
+			targets = append(targets, field.fieldName())
+		}
+	}
+	if len(targets) == 0 && len(m.expressions) == 0 {
+		return
+	}
+
+	imgui.Text("Expressions")
+	for _, field := range m.fields {
+		name := field.fieldName()
+		expression, ok := m.expressions[name]
+		if !ok {
+			continue
+		}
+
+		imgui.Text(name)
+		imgui.SameLine()
+		if imgui.InputTextV("##expression"+name, &expression.edit, imgui.InputTextFlagsEnterReturnsTrue, nil) {
+			// Keep the previous expression running until the new one parses
+			if expression.err = m.setExpression(name, expression.edit, builtinExprEnv()); expression.err == nil {
+				expression = m.expressions[name]
+			}
+		}
+		if imgui.IsItemHovered() {
+			imgui.SetTooltip("Math with the per frame built-ins, e.g. 0.5 + 0.5*sin(time*2) or mouse.x / resolution.x.\n" +
+				"Separate the components of vectors with commas. Press Enter to apply.")
+		}
+		imgui.SameLine()
+		if imgui.Button("Remove##expression" + name) {
+			delete(m.expressions, name)
+			continue
+		}
+		if expression.err != nil {
+			imgui.Text("ERROR: " + expression.err.Error())
+		}
+	}
+
+	if len(targets) == 0 {
+		return
+	}
+	drawComboString("##expressionField", &m.expressionField, targets)
+	imgui.SameLine()
+	if imgui.Button("Add expression") {
+		// Start from the current value
+		if field := m.findField(m.expressionField); field != nil {
+			source := strings.Trim(fmt.Sprint(field.savedValue().Value), "[]")
+			if err := m.setExpression(m.expressionField, strings.Replace(source, " ", ", ", -1), builtinExprEnv()); err != nil {
+				log.Printf("ERROR: %v", err)
+			}
+		}
+	}
+}
+
+// allPasses returns the material followed by its extra passes, in drawing order
+func (m *material) allPasses() []*material {
+	return append([]*material{m}, m.passes...)
+}
+
+// loadPass compiles a shader pair into a pass with the default values of its fields
+func loadPass(vertPath string, fragPath string) (*material, error) {
+	var s shader
+	if err := s.loadFromFile(vertPath, fragPath, ""); err != nil {
+		return nil, err
+	}
+
+	pass := new(material)
+	pass.init(s)
+	pass.activate()
+	return pass, nil
+}
+
+// removePass releases the extra pass at the given index
+func (m *material) removePass(index int) {
+	m.passes[index].release()
+	m.passes = append(m.passes[:index], m.passes[index+1:]...)
+	undoHistory.forgetPass(m, index)
 }
 
 func (m *material) bindTextures() {
+	bindTextureUnits(m.texBindings)
+}
+
+// bindTextureUnits binds the textures to consecutive units and points their sampler uniforms at them
+func bindTextureUnits(bindings []textureBinding) {
 	texUnit := uint32(0)
-	for _, texBinding := range m.texBindings {
+	for _, texBinding := range bindings {
 		// Set the texture uniform value
 		gl.Uniform1i(texBinding.uniformLocation, int32(texUnit))
 
 		gl.ActiveTexture(gl.TEXTURE0 + texUnit)
-		gl.BindTexture(gl.TEXTURE_2D, texBinding.glTexID)
+		if texBinding.cube {
+			gl.BindTexture(gl.TEXTURE_CUBE_MAP, texBinding.glTexID)
+		} else {
+			gl.BindTexture(gl.TEXTURE_2D, texBinding.glTexID)
+		}
 		texUnit++
 	}
 }
 
 // Field implementations and functions
 
+// drawFieldTooltip shows the annotated tooltip when the previous item is hovered
+func drawFieldTooltip(meta uniformAnnotation) {
+	if meta.tooltip != "" && imgui.IsItemHovered() {
+		imgui.SetTooltip(meta.tooltip)
+	}
+}
+
+// drawFieldComponent draws a single float component as a slider if the field has a range, otherwise as a drag field
+func drawFieldComponent(label string, value *float32, meta uniformAnnotation) bool {
+	if meta.hasRange {
+		return imgui.SliderFloat(label, value, meta.min, meta.max)
+	}
+	return imgui.DragFloat(label, value)
+}
+
+// colorEditFlags returns the color picker flags for the field annotation
+func colorEditFlags(meta uniformAnnotation) int {
+	if meta.hdr {
+		return imgui.ColorEditFlagsHDR | imgui.ColorEditFlagsFloat
+	}
+	return imgui.ColorEditFlagsNone
+}
+
+// drawColorIntensity draws the intensity of an HDR color, the largest component, and rescales the color when it is edited
+func drawColorIntensity(name string, components []*float32) bool {
+	intensity := float32(0)
+	for _, component := range components {
+		if *component > intensity {
+			intensity = *component
+		}
+	}
+
+	imgui.Text("	intensity")
+	imgui.SameLine()
+	previous := intensity
+	if !imgui.DragFloatV("##intensity"+name, &intensity, 0.01, 0, 1000, "%.2f", 1) {
+		return false
+	}
+
+	for _, component := range components {
+		if previous > 0 {
+			*component *= intensity / previous
+		} else {
+			*component = intensity
+		}
+	}
+	return true
+}
+
+// setComponents copies the saved components into the field components, extra or missing values are ignored
+func setComponents(components []*float32, values []float32) {
+	for i := 0; i < len(components) && i < len(values); i++ {
+		*components[i] = values[i]
+	}
+}
+
 // Float
 type matFieldFloat struct {
-	name  string
-	value float32
+	name     string
+	location int32
+	value    float32
+	meta     uniformAnnotation
+}
+
+func (f *matFieldFloat) fieldName() string      { return f.name }
+func (f *matFieldFloat) fieldType() uniformType { return uniformFloat }
+
+func (f *matFieldFloat) savedValue() fieldValue {
+	return fieldValue{Name: f.name, Type: uniformFloat, Value: []float32{f.value}}
+}
+
+func (f *matFieldFloat) setValue(value fieldValue) {
+	setComponents([]*float32{&f.value}, value.Value)
 }
 
-func (f *matFieldFloat) draw() {
+func (f *matFieldFloat) draw() bool {
 	imgui.Text(f.name)
+	drawFieldTooltip(f.meta)
 	imgui.SameLine()
-	imgui.DragFloat("##"+f.name, &f.value)
+	return drawFieldComponent("##"+f.name, &f.value, f.meta)
+}
+
+func (f *matFieldFloat) locate(table *uniformTable) {
+	f.location = table.location("material." + f.name)
 }
 
 func (f *matFieldFloat) apply(mat *material) {
-	uniform := gl.GetUniformLocation(mat.shader.program, gl.Str("material."+f.name+"\x00"))
-	gl.Uniform1f(uniform, f.value)
+	gl.Uniform1f(f.location, f.value)
 }
 
 // Vec2
 type matFieldVec2 struct {
-	name string
-	x    float32
-	y    float32
+	name     string
+	location int32
+	x        float32
+	y        float32
+	meta     uniformAnnotation
+}
+
+func (v2 *matFieldVec2) fieldName() string      { return v2.name }
+func (v2 *matFieldVec2) fieldType() uniformType { return uniformVec2 }
+
+func (v2 *matFieldVec2) savedValue() fieldValue {
+	return fieldValue{Name: v2.name, Type: uniformVec2, Value: []float32{v2.x, v2.y}}
 }
 
-func (v2 *matFieldVec2) draw() {
+func (v2 *matFieldVec2) setValue(value fieldValue) {
+	setComponents([]*float32{&v2.x, &v2.y}, value.Value)
+}
+
+func (v2 *matFieldVec2) draw() bool {
 	imgui.Columns(3, "")
 	imgui.Text(v2.name)
+	drawFieldTooltip(v2.meta)
 	imgui.NextColumn()
-	imgui.DragFloat("x##"+v2.name, &v2.x)
+	changed := drawFieldComponent("x##"+v2.name, &v2.x, v2.meta)
 	imgui.NextColumn()
-	imgui.DragFloat("y##"+v2.name, &v2.y)
+	changed = drawFieldComponent("y##"+v2.name, &v2.y, v2.meta) || changed
 	imgui.Columns(1, "")
+	return changed
+}
+
+func (v2 *matFieldVec2) locate(table *uniformTable) {
+	v2.location = table.location("material." + v2.name)
 }
 
 func (v2 *matFieldVec2) apply(mat *material) {
-	uniform := gl.GetUniformLocation(mat.shader.program, gl.Str("material."+v2.name+"\x00"))
-	gl.Uniform2f(uniform, v2.x, v2.y)
+	gl.Uniform2f(v2.location, v2.x, v2.y)
 }
 
 // Vec3
 type matFieldVec3 struct {
-	name string
-	x    float32
-	y    float32
-	z    float32
+	name     string
+	location int32
+	x        float32
+	y        float32
+	z        float32
+	meta     uniformAnnotation
+}
+
+func (v3 *matFieldVec3) fieldName() string      { return v3.name }
+func (v3 *matFieldVec3) fieldType() uniformType { return uniformVec3 }
+
+func (v3 *matFieldVec3) savedValue() fieldValue {
+	return fieldValue{Name: v3.name, Type: uniformVec3, Value: []float32{v3.x, v3.y, v3.z}}
 }
 
-func (v3 *matFieldVec3) draw() {
+func (v3 *matFieldVec3) setValue(value fieldValue) {
+	setComponents([]*float32{&v3.x, &v3.y, &v3.z}, value.Value)
+}
+
+func (v3 *matFieldVec3) draw() bool {
+	if v3.meta.color {
+		imgui.Text(v3.name)
+		drawFieldTooltip(v3.meta)
+		imgui.SameLine()
+		color := [3]float32{v3.x, v3.y, v3.z}
+		changed := imgui.ColorEdit3V("##"+v3.name, &color, colorEditFlags(v3.meta))
+		if changed {
+			v3.x, v3.y, v3.z = color[0], color[1], color[2]
+		}
+		if v3.meta.hdr {
+			changed = drawColorIntensity(v3.name, []*float32{&v3.x, &v3.y, &v3.z}) || changed
+		}
+		return changed
+	}
+
 	imgui.Columns(4, v3.name)
 	imgui.Text(v3.name)
+	drawFieldTooltip(v3.meta)
 	imgui.NextColumn()
-	imgui.DragFloat("x##"+v3.name, &v3.x)
+	changed := drawFieldComponent("x##"+v3.name, &v3.x, v3.meta)
 	imgui.NextColumn()
-	imgui.DragFloat("y##"+v3.name, &v3.y)
+	changed = drawFieldComponent("y##"+v3.name, &v3.y, v3.meta) || changed
 	imgui.NextColumn()
-	imgui.DragFloat("z##"+v3.name, &v3.z)
+	changed = drawFieldComponent("z##"+v3.name, &v3.z, v3.meta) || changed
 	imgui.Columns(1, "")
+	return changed
+}
+
+func (v3 *matFieldVec3) locate(table *uniformTable) {
+	v3.location = table.location("material." + v3.name)
 }
 
 func (v3 *matFieldVec3) apply(mat *material) {
-	uniform := gl.GetUniformLocation(mat.shader.program, gl.Str("material."+v3.name+"\x00"))
-	gl.Uniform3f(uniform, v3.x, v3.y, v3.z)
+	gl.Uniform3f(v3.location, v3.x, v3.y, v3.z)
 }
 
 // Vec4
 type matFieldVec4 struct {
-	name string
-	x    float32
-	y    float32
-	z    float32
-	w    float32
+	name     string
+	location int32
+	x        float32
+	y        float32
+	z        float32
+	w        float32
+	meta     uniformAnnotation
 }
 
-func (v4 *matFieldVec4) draw() {
+func (v4 *matFieldVec4) fieldName() string      { return v4.name }
+func (v4 *matFieldVec4) fieldType() uniformType { return uniformVec4 }
+
+func (v4 *matFieldVec4) savedValue() fieldValue {
+	return fieldValue{Name: v4.name, Type: uniformVec4, Value: []float32{v4.x, v4.y, v4.z, v4.w}}
+}
+
+func (v4 *matFieldVec4) setValue(value fieldValue) {
+	setComponents([]*float32{&v4.x, &v4.y, &v4.z, &v4.w}, value.Value)
+}
+
+func (v4 *matFieldVec4) draw() bool {
+	if v4.meta.color {
+		imgui.Text(v4.name)
+		drawFieldTooltip(v4.meta)
+		imgui.SameLine()
+		color := [4]float32{v4.x, v4.y, v4.z, v4.w}
+		changed := imgui.ColorEdit4V("##"+v4.name, &color, colorEditFlags(v4.meta))
+		if changed {
+			v4.x, v4.y, v4.z, v4.w = color[0], color[1], color[2], color[3]
+		}
+		if v4.meta.hdr {
+			// Alpha is not part of the intensity
+			changed = drawColorIntensity(v4.name, []*float32{&v4.x, &v4.y, &v4.z}) || changed
+		}
+		return changed
+	}
+
 	imgui.Columns(5, v4.name)
 	imgui.Text(v4.name)
+	drawFieldTooltip(v4.meta)
 	imgui.NextColumn()
-	imgui.DragFloat("x##"+v4.name, &v4.x)
+	changed := drawFieldComponent("x##"+v4.name, &v4.x, v4.meta)
 	imgui.NextColumn()
-	imgui.DragFloat("y##"+v4.name, &v4.y)
+	changed = drawFieldComponent("y##"+v4.name, &v4.y, v4.meta) || changed
 	imgui.NextColumn()
-	imgui.DragFloat("z##"+v4.name, &v4.z)
+	changed = drawFieldComponent("z##"+v4.name, &v4.z, v4.meta) || changed
 	imgui.NextColumn()
-	imgui.DragFloat("w##"+v4.name, &v4.w)
+	changed = drawFieldComponent("w##"+v4.name, &v4.w, v4.meta) || changed
 	imgui.Columns(1, "")
+	return changed
+}
+
+func (v4 *matFieldVec4) locate(table *uniformTable) {
+	v4.location = table.location("material." + v4.name)
 }
 
 func (v4 *matFieldVec4) apply(mat *material) {
-	uniform := gl.GetUniformLocation(mat.shader.program, gl.Str("material."+v4.name+"\x00"))
-	gl.Uniform4f(uniform, v4.x, v4.y, v4.z, v4.w)
+	gl.Uniform4f(v4.location, v4.x, v4.y, v4.z, v4.w)
 }
 
-// Texture
+// Texture, sampler2D or samplerCube
 type matFieldTexture struct {
 	name     string
+	location int32
 	tex      texture
 	filePath string
+	sampler  samplerSettings
+	meta     uniformAnnotation
+	cube     bool
+	// failed is the path and sampler that didn't load, which aren't tried again until they change
+	failed textureKey
+}
+
+func (t *matFieldTexture) fieldName() string { return t.name }
+
+func (t *matFieldTexture) fieldType() uniformType {
+	if t.cube {
+		return uniformTexCube
+	}
+	return uniformTex2D
+}
+
+func (t *matFieldTexture) savedValue() fieldValue {
+	sampler := t.sampler
+	return fieldValue{Name: t.name, Type: t.fieldType(), Texture: t.filePath, Sampler: &sampler}
+}
+
+func (t *matFieldTexture) setValue(value fieldValue) {
+	t.filePath = value.Texture
+	if value.Sampler != nil {
+		t.sampler = *value.Sampler
+	}
 }
 
-func (t *matFieldTexture) draw() {
+// draw reports a change when Enter is pressed, so partially typed paths aren't loaded, or when a sampler setting changes
+func (t *matFieldTexture) draw() bool {
 	imgui.Text(t.name)
+	drawFieldTooltip(t.meta)
 	imgui.SameLine()
-	imgui.InputText("##"+t.name, &t.filePath)
+	changed := imgui.InputTextV("##"+t.name, &t.filePath, imgui.InputTextFlagsEnterReturnsTrue, nil)
+	if t.cube && imgui.IsItemHovered() {
+		imgui.SetTooltip("A cross or panorama image, or six faces with * in place of px, nx, py, ny, pz and nz")
+	}
+
+	if imgui.TreeNode("sampler##" + t.name) {
+		changed = t.sampler.drawUI(t.name) || changed
+		imgui.TreePop()
+	}
+	return changed
 }
 
+func (t *matFieldTexture) locate(table *uniformTable) {
+	t.location = table.location("material." + t.name)
+}
+
+// apply loads the texture when the path or the sampler settings changed. The material binds it when drawing.
 func (t *matFieldTexture) apply(mat *material) {
-	texError := t.tex.loadFromFile(t.filePath)
+	if t.filePath == "" {
+		t.tex.delete()
+		t.failed = textureKey{}
+		return
+	}
 
-	if texError != nil {
+	key := textureKey{path: t.filePath, sampler: t.sampler, cube: t.cube}
+	loaded := textureKey{path: t.tex.filePath, sampler: t.tex.sampler, cube: t.tex.cube}
+	if key == loaded || key == t.failed {
+		return
+	}
+	if texError := t.tex.load(key); texError != nil {
+		t.failed = key
 		fmt.Println("Bad texture" + texError.Error())
+		return
+	}
+	t.failed = textureKey{}
+}
+
+// Int, ivec2, ivec3 and ivec4
+type matFieldInt struct {
+	name     string
+	location int32
+	uType    uniformType
+	values   [4]int32
+	meta     uniformAnnotation
+}
+
+// intComponents returns the number of components of an integer uniform type
+func intComponents(uType uniformType) int {
+	switch uType {
+	case uniformIVec2:
+		return 2
+	case uniformIVec3:
+		return 3
+	case uniformIVec4:
+		return 4
+	default:
+		return 1
+	}
+}
+
+func (i *matFieldInt) fieldName() string      { return i.name }
+func (i *matFieldInt) fieldType() uniformType { return i.uType }
+
+func (i *matFieldInt) savedValue() fieldValue {
+	value := make([]float32, intComponents(i.uType))
+	for c := range value {
+		value[c] = float32(i.values[c])
+	}
+	return fieldValue{Name: i.name, Type: i.uType, Value: value}
+}
+
+func (i *matFieldInt) setValue(value fieldValue) {
+	for c := 0; c < intComponents(i.uType) && c < len(value.Value); c++ {
+		i.values[c] = int32(value.Value[c])
+	}
+}
+
+func (i *matFieldInt) draw() bool {
+	count := intComponents(i.uType)
+	labels := []string{"x", "y", "z", "w"}
+
+	imgui.Columns(count+1, i.name)
+	imgui.Text(i.name)
+	drawFieldTooltip(i.meta)
+	changed := false
+	for c := 0; c < count; c++ {
+		imgui.NextColumn()
+		label := labels[c] + "##" + i.name
+		if i.meta.hasRange {
+			changed = imgui.SliderInt(label, &i.values[c], int32(i.meta.min), int32(i.meta.max)) || changed
+		} else {
+			changed = imgui.DragInt(label, &i.values[c]) || changed
+		}
+	}
+	imgui.Columns(1, "")
+	return changed
+}
+
+func (i *matFieldInt) locate(table *uniformTable) {
+	i.location = table.location("material." + i.name)
+}
+
+func (i *matFieldInt) apply(mat *material) {
+	v := i.values
+	switch intComponents(i.uType) {
+	case 1:
+		gl.Uniform1i(i.location, v[0])
+	case 2:
+		gl.Uniform2i(i.location, v[0], v[1])
+	case 3:
+		gl.Uniform3i(i.location, v[0], v[1], v[2])
+	case 4:
+		gl.Uniform4i(i.location, v[0], v[1], v[2], v[3])
+	}
+}
+
+// Bool
+type matFieldBool struct {
+	name     string
+	location int32
+	value    bool
+	meta     uniformAnnotation
+}
+
+func (b *matFieldBool) fieldName() string      { return b.name }
+func (b *matFieldBool) fieldType() uniformType { return uniformBool }
+
+func (b *matFieldBool) savedValue() fieldValue {
+	value := float32(0)
+	if b.value {
+		value = 1
 	}
+	return fieldValue{Name: b.name, Type: uniformBool, Value: []float32{value}}
+}
 
-	// Get the uniform location
-	uniform := gl.GetUniformLocation(mat.shader.program, gl.Str("material."+t.name+"\x00"))
+func (b *matFieldBool) setValue(value fieldValue) {
+	if len(value.Value) != 0 {
+		b.value = value.Value[0] != 0
+	}
+}
+
+func (b *matFieldBool) draw() bool {
+	changed := imgui.Checkbox(b.name, &b.value)
+	drawFieldTooltip(b.meta)
+	return changed
+}
 
-	// Create and add a new texture binding struct
-	var texBind textureBinding
-	texBind.glTexID = t.tex.id
-	texBind.uniformLocation = uniform
-	mat.texBindings = append(mat.texBindings, texBind)
+func (b *matFieldBool) locate(table *uniformTable) {
+	b.location = table.location("material." + b.name)
+}
+
+func (b *matFieldBool) apply(mat *material) {
+	value := int32(0)
+	if b.value {
+		value = 1
+	}
+	gl.Uniform1i(b.location, value)
+}
+
+// Mat3 and mat4, composed from a translation, a rotation in degrees applied in X, Y, Z order and a scale.
+// A mat3 only uses the rotation and the scale. The saved value is the composition, not the matrix.
+type matFieldMatrix struct {
+	name      string
+	location  int32
+	uType     uniformType
+	translate [3]float32
+	rotate    [3]float32
+	scale     [3]float32
+	meta      uniformAnnotation
+}
+
+func (mf *matFieldMatrix) fieldName() string      { return mf.name }
+func (mf *matFieldMatrix) fieldType() uniformType { return mf.uType }
+
+func (mf *matFieldMatrix) savedValue() fieldValue {
+	value := make([]float32, 0, 9)
+	value = append(value, mf.translate[:]...)
+	value = append(value, mf.rotate[:]...)
+	value = append(value, mf.scale[:]...)
+	return fieldValue{Name: mf.name, Type: mf.uType, Value: value}
+}
+
+func (mf *matFieldMatrix) setValue(value fieldValue) {
+	components := make([]*float32, 0, 9)
+	for _, vector := range []*[3]float32{&mf.translate, &mf.rotate, &mf.scale} {
+		components = append(components, &vector[0], &vector[1], &vector[2])
+	}
+	setComponents(components, value.Value)
+}
+
+// matrix returns translate * rotate * scale
+func (mf *matFieldMatrix) matrix() mgl32.Mat4 {
+	rotation := mgl32.HomogRotate3DZ(mgl32.DegToRad(mf.rotate[2])).
+		Mul4(mgl32.HomogRotate3DY(mgl32.DegToRad(mf.rotate[1]))).
+		Mul4(mgl32.HomogRotate3DX(mgl32.DegToRad(mf.rotate[0])))
+	translation := mgl32.Translate3D(mf.translate[0], mf.translate[1], mf.translate[2])
+	scale := mgl32.Scale3D(mf.scale[0], mf.scale[1], mf.scale[2])
+	return translation.Mul4(rotation.Mul4(scale))
+}
+
+// drawVectorRow draws a labelled row of three drag fields
+func drawVectorRow(label string, id string, vector *[3]float32) bool {
+	imgui.Columns(4, id)
+	imgui.Text("	" + label)
+	imgui.NextColumn()
+	changed := imgui.DragFloat("x##"+id, &vector[0])
+	imgui.NextColumn()
+	changed = imgui.DragFloat("y##"+id, &vector[1]) || changed
+	imgui.NextColumn()
+	changed = imgui.DragFloat("z##"+id, &vector[2]) || changed
+	imgui.Columns(1, "")
+	return changed
+}
+
+func (mf *matFieldMatrix) draw() bool {
+	imgui.Text(mf.name)
+	drawFieldTooltip(mf.meta)
+
+	changed := false
+	if mf.uType == uniformMat4 {
+		changed = drawVectorRow("translate", "translate"+mf.name, &mf.translate)
+	}
+	changed = drawVectorRow("rotate", "rotate"+mf.name, &mf.rotate) || changed
+	changed = drawVectorRow("scale", "scale"+mf.name, &mf.scale) || changed
+	return changed
+}
+
+func (mf *matFieldMatrix) locate(table *uniformTable) {
+	mf.location = table.location("material." + mf.name)
+}
+
+func (mf *matFieldMatrix) apply(mat *material) {
+	matrix := mf.matrix()
+	if mf.uType == uniformMat3 {
+		matrix3 := matrix.Mat3()
+		gl.UniformMatrix3fv(mf.location, 1, false, &matrix3[0])
+		return
+	}
+	gl.UniformMatrix4fv(mf.location, 1, false, &matrix[0])
 }
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestRebuildTexBindings(t *testing.T) {
+	mat := material{fields: []materialField{
+		&matFieldTexture{name: "albedo", location: 2, tex: texture{id: 5}},
+		&matFieldFloat{name: "roughness", location: 3},
+		&matFieldTexture{name: "unset", location: 4},
+		&matFieldTexture{name: "mask", location: 6, tex: texture{id: 7}},
+	}}
+
+	// Rebuilding again must not pile up bindings
+	mat.rebuildTexBindings()
+	mat.rebuildTexBindings()
+
+	assert.Equal(t, len(mat.texBindings), 2)
+	assert.Equal(t, mat.texBindings[0], textureBinding{glTexID: 5, uniformLocation: 2})
+	assert.Equal(t, mat.texBindings[1], textureBinding{glTexID: 7, uniformLocation: 6})
+}
+
+func TestTypedMaterialFields(t *testing.T) {
+	testShader := `#version 330
+	struct Material {
+		int steps; // @range(1,16) @default(4)
+		ivec3 cells;
+		bool useMask; // @default(1)
+		mat3 uvTransform;
+		mat4 offset;
+		vec3 emission; // @hdr
+	};`
+
+	uniforms := getUniforms(testShader)
+	assert.Equal(t, len(uniforms), 6)
+	assert.Equal(t, uniforms[0].uType, uniformInt)
+	assert.Equal(t, uniforms[1].uType, uniformIVec3)
+	assert.Equal(t, uniforms[2].uType, uniformBool)
+	assert.Equal(t, uniforms[3].uType, uniformMat3)
+	assert.Equal(t, uniforms[4].uType, uniformMat4)
+	assert.Assert(t, uniforms[5].annotation.hdr && uniforms[5].annotation.color)
+
+	cells := &matFieldInt{name: "cells", uType: uniformIVec3}
+	cells.setValue(fieldValue{Value: []float32{2, 3, 4, 5}})
+	assert.DeepEqual(t, cells.values, [4]int32{2, 3, 4, 0})
+	assert.DeepEqual(t, cells.savedValue().Value, []float32{2, 3, 4})
+
+	useMask := &matFieldBool{name: "useMask"}
+	useMask.setValue(fieldValue{Value: []float32{1}})
+	assert.Equal(t, useMask.value, true)
+
+	offset := &matFieldMatrix{name: "offset", uType: uniformMat4, scale: [3]float32{1, 1, 1}}
+	offset.setValue(fieldValue{Value: []float32{1, 2, 3, 0, 0, 0, 2, 2, 2}})
+	matrix := offset.matrix()
+	assert.Equal(t, matrix[0], float32(2), "Scale must be applied")
+	assert.Equal(t, matrix[12], float32(1), "Translation must be applied after scale")
+	assert.Equal(t, matrix[14], float32(3))
+	assert.DeepEqual(t, offset.savedValue().Value, []float32{1, 2, 3, 0, 0, 0, 2, 2, 2})
+}
+
+func TestCarryOver(t *testing.T) {
+	previous := material{
+		fields: []materialField{
+			&matFieldFloat{name: "roughness", value: 0.25},
+			&matFieldVec3{name: "tint", x: 0.1, y: 0.2, z: 0.3},
+			&matFieldFloat{name: "steps", value: 3},
+			&matFieldFloat{name: "oldParam", value: 1},
+		},
+		enabledKeywords: map[string]bool{"USE_MASK": true, "REMOVED": true},
+		renderState:     renderState{DepthTest: true, DepthFunc: depthAlways, Cull: cullNone},
+		tags:            []string{"metal"},
+		passes:          []*material{{tags: []string{"outline"}}},
+	}
+	recompiled := material{
+		shader: shader{keywords: []string{"USE_MASK"}},
+		fields: []materialField{
+			&matFieldFloat{name: "roughness"},
+			&matFieldVec3{name: "tint"},
+			&matFieldInt{name: "steps", uType: uniformInt},
+			&matFieldFloat{name: "metallic", value: 0.5},
+		},
+		enabledKeywords: make(map[string]bool),
+		renderState:     defaultRenderState(),
+	}
+
+	changes := recompiled.carryOver(&previous)
+	assert.DeepEqual(t, changes.added, []string{"metallic"})
+	assert.DeepEqual(t, changes.removed, []string{"oldParam"})
+	assert.DeepEqual(t, changes.retyped, []string{"steps (float to int)"})
+	assert.Equal(t, changes.String(), "added: metallic; removed: oldParam; retyped: steps (float to int)")
+
+	assert.Equal(t, recompiled.fields[0].(*matFieldFloat).value, float32(0.25))
+	assert.DeepEqual(t, recompiled.fields[1].savedValue().Value, []float32{0.1, 0.2, 0.3})
+	assert.DeepEqual(t, recompiled.fields[2].savedValue().Value, []float32{0})
+	assert.Equal(t, recompiled.fields[3].(*matFieldFloat).value, float32(0.5))
+	assert.DeepEqual(t, recompiled.activeKeywords(), []string{"USE_MASK"})
+	assert.Equal(t, recompiled.renderState, previous.renderState)
+	assert.DeepEqual(t, recompiled.tags, []string{"metal"})
+	assert.Equal(t, len(recompiled.passes), 1)
+	assert.Equal(t, len(previous.passes), 0)
+	assert.Equal(t, len(recompiled.allPasses()), 2)
+
+	assert.Equal(t, fieldChanges{}.String(), "fields unchanged")
+}
+
+func TestTextureFieldFailedLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "texture")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "broken.png")
+	assert.NilError(t, ioutil.WriteFile(path, []byte("not an image"), 0644))
+
+	field := &matFieldTexture{name: "albedo", filePath: path}
+	field.apply(nil)
+	assert.Equal(t, field.failed, textureKey{path: path})
+	assert.Equal(t, field.tex.filePath, "")
+
+	// The same path isn't decoded again, even once the file is fixed
+	wood, err := ioutil.ReadFile("Assets/wood.png")
+	assert.NilError(t, err)
+	assert.NilError(t, ioutil.WriteFile(path, wood, 0644))
+	field.apply(nil)
+	assert.Equal(t, field.tex.filePath, "")
+
+	field.filePath = ""
+	field.apply(nil)
+	assert.Equal(t, field.failed, textureKey{})
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+)
+
+// materialFileExtension is the extension of saved materials
+const materialFileExtension = ".mat"
+
+// materialFile is the JSON layout of a .mat file. A material references either separate
+// stage files or a single-file .glsl shader.
+type materialFile struct {
+	Vertex      string       `json:"vertex,omitempty"`
+	Fragment    string       `json:"fragment,omitempty"`
+	Geometry    string       `json:"geometry,omitempty"`
+	GLSL        string       `json:"glsl,omitempty"`
+	Tags        []string     `json:"tags,omitempty"`
+	Keywords    []string     `json:"keywords,omitempty"`
+	Fields      []fieldValue `json:"fields"`
+	RenderState renderState  `json:"renderState"`
+	// Passes are drawn after the material in order. They can't have passes of their own.
+	Passes    []materialFile `json:"passes,omitempty"`
+	Animation *animation     `json:"animation,omitempty"`
+}
+
+// UnmarshalJSON keeps the default render state entries that the material or pass leaves out
+func (f *materialFile) UnmarshalJSON(bytes []byte) error {
+	type plainFile materialFile
+	file := plainFile{RenderState: defaultRenderState()}
+	if err := json.Unmarshal(bytes, &file); err != nil {
+		return err
+	}
+	*f = materialFile(file)
+	return nil
+}
+
+// validate checks that the material and its passes reference a shader
+func (f *materialFile) validate() error {
+	if f.GLSL == "" && (f.Vertex == "" || f.Fragment == "") {
+		return fmt.Errorf("a glsl file or a vertex and a fragment shader are required")
+	}
+	for i, pass := range f.Passes {
+		if len(pass.Passes) != 0 {
+			return fmt.Errorf("pass %d: a pass can't have passes of its own", i+1)
+		}
+		if err := pass.validate(); err != nil {
+			return fmt.Errorf("pass %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+// fieldValue is the serialized value of a material field. Numeric fields store their
+// components in Value, texture fields store the image path and sampler settings.
+// Fields driven by an expression store its source too.
+type fieldValue struct {
+	Name       string           `json:"name"`
+	Type       uniformType      `json:"type"`
+	Value      []float32        `json:"value,omitempty"`
+	Texture    string           `json:"texture,omitempty"`
+	Sampler    *samplerSettings `json:"sampler,omitempty"`
+	Expression string           `json:"expression,omitempty"`
+}
+
+// file returns the serializable description of the material
+func (m *material) file() materialFile {
+	file := materialFile{
+		Vertex:      m.shader.vertPath,
+		Fragment:    m.shader.fragPath,
+		Geometry:    m.shader.geomPath,
+		GLSL:        m.shader.glslPath,
+		Tags:        m.tags,
+		Keywords:    m.activeKeywords(),
+		Fields:      make([]fieldValue, 0, len(m.fields)),
+		RenderState: m.renderState,
+	}
+	for _, field := range m.fields {
+		value := field.savedValue()
+		if expression, ok := m.expressions[field.fieldName()]; ok {
+			value.Expression = expression.source
+		}
+		file.Fields = append(file.Fields, value)
+	}
+	for _, pass := range m.passes {
+		file.Passes = append(file.Passes, pass.file())
+	}
+	if len(m.animation.Tracks) != 0 {
+		anim := m.animation
+		file.Animation = &anim
+	}
+	return file
+}
+
+// save writes the material to a .mat file
+func (m *material) save(path string) error {
+	bytes, err := json.MarshalIndent(m.file(), "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, bytes, 0644)
+}
+
+// restore sets the saved field values, keywords and render state and uploads the uniforms.
+// Saved fields the shader no longer declares, or declares with another type, are skipped.
+func (m *material) restore(file materialFile) {
+	for _, value := range file.Fields {
+		field := m.findField(value.Name)
+		if field == nil {
+			log.Printf("WARNING: material field %q no longer exists in the shader", value.Name)
+			continue
+		}
+		if field.fieldType() != value.Type {
+			log.Printf("WARNING: material field %q changed type from %s to %s", value.Name, value.Type, field.fieldType())
+			continue
+		}
+		field.setValue(value)
+
+		if value.Expression != "" {
+			if err := m.setExpression(value.Name, value.Expression, builtinExprEnv()); err != nil {
+				log.Printf("WARNING: expression of material field %q: %v", value.Name, err)
+			}
+		}
+	}
+
+	m.renderState = file.RenderState
+	m.tags = file.Tags
+	if file.Animation != nil {
+		m.animation = *file.Animation
+	}
+
+	for _, keyword := range file.Keywords {
+		m.enabledKeywords[keyword] = true
+	}
+	m.activate()
+}
+
+// findField returns the field with the given name, or nil
+func (m *material) findField(name string) materialField {
+	for _, field := range m.fields {
+		if field.fieldName() == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// readMaterialFile parses a .mat file. Missing render state entries keep their defaults.
+func readMaterialFile(path string) (materialFile, error) {
+	var file materialFile
+
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return file, err
+	}
+	if err := json.Unmarshal(bytes, &file); err != nil {
+		return file, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := file.validate(); err != nil {
+		return file, fmt.Errorf("%s: %v", path, err)
+	}
+	return file, nil
+}
+
+// loadMaterial compiles the shader referenced by a .mat file and restores the saved values.
+// It only needs a current GL context, not the GUI, so headless code can load materials too.
+func loadMaterial(path string) (*material, error) {
+	file, err := readMaterialFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	mat, err := compileMaterialFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for i, passFile := range file.Passes {
+		pass, err := compileMaterialFile(passFile)
+		if err != nil {
+			mat.release()
+			return nil, fmt.Errorf("%s: pass %d: %v", path, i+1, err)
+		}
+		mat.passes = append(mat.passes, pass)
+	}
+	return mat, nil
+}
+
+// compileMaterialFile compiles the shader of a material or pass and restores its saved values
+func compileMaterialFile(file materialFile) (*material, error) {
+	var s shader
+	var err error
+	if file.GLSL != "" {
+		err = s.loadFromGLSLFile(file.GLSL)
+	} else {
+		err = s.loadFromFile(file.Vertex, file.Fragment, file.Geometry)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	mat := new(material)
+	mat.init(s)
+	mat.restore(file)
+	return mat, nil
+}
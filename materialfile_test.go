@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestFieldValues(t *testing.T) {
+	vec3 := &matFieldVec3{name: "color", x: 1, y: 2, z: 3}
+	saved := vec3.savedValue()
+	assert.Equal(t, saved.Name, "color")
+	assert.Equal(t, saved.Type, uniformVec3)
+	assert.DeepEqual(t, saved.Value, []float32{1, 2, 3})
+
+	restored := &matFieldVec3{name: "color"}
+	restored.setValue(saved)
+	assert.DeepEqual(t, restored.savedValue().Value, saved.Value)
+
+	// Missing components keep their value
+	restored.setValue(fieldValue{Name: "color", Type: uniformVec3, Value: []float32{5}})
+	assert.Equal(t, restored.x, float32(5))
+	assert.Equal(t, restored.y, float32(2))
+
+	tex := &matFieldTexture{name: "albedo", filePath: "Assets/wood.png", sampler: defaultSamplerSettings()}
+	tex.sampler.WrapS = wrapRepeat
+	saved = tex.savedValue()
+	assert.Equal(t, saved.Texture, "Assets/wood.png")
+	assert.Equal(t, saved.Sampler.WrapS, wrapRepeat)
+
+	restoredTex := &matFieldTexture{name: "albedo"}
+	restoredTex.setValue(saved)
+	assert.Equal(t, restoredTex.filePath, "Assets/wood.png")
+	assert.Equal(t, restoredTex.sampler, tex.sampler)
+}
+
+func TestReadMaterialFile(t *testing.T) {
+	file, err := readMaterialFile("Assets/cellShade.mat")
+	assert.NilError(t, err)
+	assert.Equal(t, file.Vertex, "Assets/cellShadeColor.vert")
+	assert.Equal(t, len(file.Fields), 4)
+	assert.Equal(t, file.Fields[1].Name, "specPower")
+	assert.DeepEqual(t, file.Fields[1].Value, []float32{32})
+	assert.Equal(t, file.Fields[2].Sampler.MinFilter, filterLinear)
+	assert.Equal(t, file.RenderState, defaultRenderState())
+
+	// Passes keep the default render state entries they leave out too
+	assert.Equal(t, len(file.Passes), 1)
+	assert.Equal(t, file.Passes[0].Vertex, "Assets/outline.vert")
+	assert.Equal(t, len(file.Passes[0].Fields), 2)
+	assert.Equal(t, file.Passes[0].RenderState.Cull, cullFront)
+	assert.Equal(t, file.Passes[0].RenderState.DepthTest, true)
+	assert.Equal(t, file.Passes[0].RenderState.Blend, blendOpaque)
+
+	dir, err := ioutil.TempDir("", "materials")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+
+	// Render state entries that are left out keep their defaults
+	path := filepath.Join(dir, "partial.mat")
+	assert.NilError(t, ioutil.WriteFile(path, []byte(`{"glsl": "Assets/blinnPhongColor.glsl", "renderState": {"cull": "none"}}`), 0644))
+	file, err = readMaterialFile(path)
+	assert.NilError(t, err)
+	assert.Equal(t, file.RenderState.Cull, cullNone)
+	assert.Equal(t, file.RenderState.DepthFunc, depthLess)
+
+	path = filepath.Join(dir, "noshader.mat")
+	assert.NilError(t, ioutil.WriteFile(path, []byte(`{"vertex": "Assets/unlitColor.vert"}`), 0644))
+	_, err = readMaterialFile(path)
+	assert.ErrorContains(t, err, "required")
+
+	path = filepath.Join(dir, "badpass.mat")
+	assert.NilError(t, ioutil.WriteFile(path, []byte(`{"glsl": "Assets/blinnPhongColor.glsl", "passes": [{"glsl": "Assets/blinnPhongColor.glsl"}, {"fragment": "Assets/outline.frag"}]}`), 0644))
+	_, err = readMaterialFile(path)
+	assert.ErrorContains(t, err, "pass 2: a glsl file or a vertex and a fragment shader are required")
+}
+
+func TestSavedSamplerSettings(t *testing.T) {
+	file, err := readMaterialFile("Assets/stonewall.mat")
+	assert.NilError(t, err)
+	sampler := *file.Fields[1].Sampler
+	assert.Equal(t, sampler, samplerSettings{
+		WrapS:      wrapRepeat,
+		WrapT:      wrapRepeat,
+		MinFilter:  filterLinear,
+		MagFilter:  filterLinear,
+		Mipmaps:    true,
+		Anisotropy: 8,
+	})
+	assert.Equal(t, sampler.String(), "repeat/repeat linear/linear mips 8x")
+
+	restored := &matFieldTexture{name: "tex", sampler: defaultSamplerSettings()}
+	restored.setValue(file.Fields[1])
+	assert.Equal(t, restored.savedValue().Sampler.Mipmaps, true)
+	assert.Equal(t, defaultSamplerSettings().String(), "clamp/clamp linear/linear")
+}
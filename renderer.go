@@ -9,10 +9,12 @@ type renderer struct {
 	vao      uint32
 	vbo      uint32
 	verts    []float32
-	material material
+	material *material
+	// instance, when set, overrides some of the material values for the next draw calls
+	instance *materialInstance
 }
 
-func (r *renderer) setData(verts []float32, material material) {
+func (r *renderer) setData(verts []float32, material *material) {
 	r.verts = verts
 	r.material = material
 	gl.GenVertexArrays(1, &r.vao)
@@ -22,51 +24,47 @@ func (r *renderer) setData(verts []float32, material material) {
 	gl.BindBuffer(gl.ARRAY_BUFFER, r.vbo)
 	gl.BufferData(gl.ARRAY_BUFFER, len(r.verts)*4, gl.Ptr(r.verts), gl.STATIC_DRAW)
 
-	// Get the vertex attribute from the shader and point it to data
-	vertAttrib := uint32(gl.GetAttribLocation(r.material.shader.program, gl.Str("vert\x00")))
-	gl.EnableVertexAttribArray(vertAttrib)
-	gl.VertexAttribPointer(vertAttrib, 3, gl.FLOAT, false, 8*4, gl.PtrOffset(0))
-
-	// Get the texCoord attribute from the shader and point it to data
-	texCoordAttrib := uint32(gl.GetAttribLocation(r.material.shader.program, gl.Str("vertTexCoord\x00")))
-	gl.EnableVertexAttribArray(texCoordAttrib)
-	gl.VertexAttribPointer(texCoordAttrib, 2, gl.FLOAT, false, 8*4, gl.PtrOffset(3*4))
-
-	// Get the normal attribute from the shader and point it to data
-	normalAttrib := uint32(gl.GetAttribLocation(r.material.shader.program, gl.Str("normal\x00")))
-	gl.EnableVertexAttribArray(normalAttrib)
-	gl.VertexAttribPointer(normalAttrib, 3, gl.FLOAT, false, 8*4, gl.PtrOffset(5*4))
+	// Point the vertex attributes to the data. Their locations are bound when the program is linked.
+	for _, attrib := range vertexAttributes {
+		gl.EnableVertexAttribArray(attrib.location)
+		gl.VertexAttribPointer(attrib.location, attrib.size, gl.FLOAT, false, 8*4, gl.PtrOffset(attrib.offset*4))
+	}
 
 	gl.BindFragDataLocation(r.material.shader.program, 0, gl.Str("outputColor\x00"))
 }
 
+// issueDrawCall draws the mesh once per pass of the material
 func (r *renderer) issueDrawCall(model mgl32.Mat4, view mgl32.Mat4, projection mgl32.Mat4) {
-	// Select the shader to use
-	gl.UseProgram(r.material.shader.program)
-
-	// Set the modelUniform for the object
-	modelUniform := gl.GetUniformLocation(r.material.shader.program, gl.Str(modelMatrixName+"\x00"))
-	gl.UniformMatrix4fv(modelUniform, 1, false, &model[0])
-
-	// Set the viewUniform for the object
-	viewUniform := gl.GetUniformLocation(r.material.shader.program, gl.Str(viewMatrixName+"\x00"))
-	gl.UniformMatrix4fv(viewUniform, 1, false, &view[0])
-
-	// Set the projectionUniform for the object
-	projectionUniform := gl.GetUniformLocation(r.material.shader.program, gl.Str(projMatrixName+"\x00"))
-	gl.UniformMatrix4fv(projectionUniform, 1, false, &projection[0])
-
-	// Also pass the combined MVP uniform for convenience
-	MVP := projection.Mul4(view.Mul4(model))
-	MVPUniform := gl.GetUniformLocation(r.material.shader.program, gl.Str(mvpMatrixName+"\x00"))
-	gl.UniformMatrix4fv(MVPUniform, 1, false, &MVP[0])
+	ctx := objectContext{model, view, projection}
 
 	// Bind the vertex array object
 	gl.BindVertexArray(r.vao)
 
-	// Bind the material textures
-	r.material.bindTextures()
-
-	// Issue drawcall
-	gl.DrawArrays(gl.TRIANGLES, 0, int32(len(r.verts)))
+	for _, pass := range r.material.allPasses() {
+		// Select the shader to use
+		gl.UseProgram(pass.shader.program)
+		pass.renderState.apply()
+
+		// Set the per object built-in uniforms such as the model, view and projection matrices
+		pass.uniforms.applyBuiltins(scopeObject, &ctx)
+
+		// Bind the material textures, or upload the overrides and textures of the instance,
+		// which only override the first pass
+		instance := r.instance
+		if pass != r.material {
+			instance = nil
+		}
+		if instance != nil {
+			instance.bind()
+		} else {
+			pass.bindTextures()
+		}
+
+		// Issue drawcall
+		gl.DrawArrays(gl.TRIANGLES, 0, int32(len(r.verts)))
+
+		if instance != nil {
+			instance.unbind()
+		}
+	}
 }
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-gl/gl/v3.2-core/gl"
+	"github.com/inkyblackness/imgui-go"
+)
+
+// Blend modes, depth comparison functions, cull modes and polygon modes as stored in material files
+const (
+	blendOpaque        string = "opaque"
+	blendAlpha         string = "alpha"
+	blendAdditive      string = "additive"
+	blendPremultiplied string = "premultiplied"
+
+	depthLess      string = "less"
+	depthLessEqual string = "lequal"
+	depthAlways    string = "always"
+
+	cullBack  string = "back"
+	cullFront string = "front"
+	cullNone  string = "none"
+
+	polygonFill string = "fill"
+	polygonLine string = "line"
+)
+
+var (
+	blendModes   = []string{blendOpaque, blendAlpha, blendAdditive, blendPremultiplied}
+	depthFuncs   = []string{depthLess, depthLessEqual, depthAlways}
+	cullModes    = []string{cullBack, cullFront, cullNone}
+	polygonModes = []string{polygonFill, polygonLine}
+)
+
+// renderState holds the fixed function state a material is drawn with
+type renderState struct {
+	Blend       string `json:"blend"`
+	DepthTest   bool   `json:"depthTest"`
+	DepthWrite  bool   `json:"depthWrite"`
+	DepthFunc   string `json:"depthFunc"`
+	Cull        string `json:"cull"`
+	PolygonMode string `json:"polygonMode"`
+	// OffsetFactor and OffsetUnits push the depth of the polygons back, or forward when negative,
+	// e.g. to draw decals or outlines on top of coplanar geometry
+	OffsetFactor float32 `json:"offsetFactor"`
+	OffsetUnits  float32 `json:"offsetUnits"`
+}
+
+func defaultRenderState() renderState {
+	return renderState{
+		Blend:       blendOpaque,
+		DepthTest:   true,
+		DepthWrite:  true,
+		DepthFunc:   depthLess,
+		Cull:        cullBack,
+		PolygonMode: polygonFill,
+	}
+}
+
+func glDepthFunc(depthFunc string) uint32 {
+	switch depthFunc {
+	case depthLessEqual:
+		return gl.LEQUAL
+	case depthAlways:
+		return gl.ALWAYS
+	default:
+		return gl.LESS
+	}
+}
+
+// apply sets the GL state for the next draw calls
+func (rs renderState) apply() {
+	switch rs.Blend {
+	case blendAlpha:
+		gl.Enable(gl.BLEND)
+		gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+	case blendAdditive:
+		gl.Enable(gl.BLEND)
+		gl.BlendFunc(gl.SRC_ALPHA, gl.ONE)
+	case blendPremultiplied:
+		gl.Enable(gl.BLEND)
+		gl.BlendFunc(gl.ONE, gl.ONE_MINUS_SRC_ALPHA)
+	default:
+		gl.Disable(gl.BLEND)
+	}
+	gl.BlendEquation(gl.FUNC_ADD)
+
+	if rs.DepthTest {
+		gl.Enable(gl.DEPTH_TEST)
+		gl.DepthFunc(glDepthFunc(rs.DepthFunc))
+	} else {
+		gl.Disable(gl.DEPTH_TEST)
+	}
+	gl.DepthMask(rs.DepthWrite)
+
+	switch rs.Cull {
+	case cullNone:
+		gl.Disable(gl.CULL_FACE)
+	case cullFront:
+		gl.Enable(gl.CULL_FACE)
+		gl.CullFace(gl.FRONT)
+	default:
+		gl.Enable(gl.CULL_FACE)
+		gl.CullFace(gl.BACK)
+	}
+
+	if rs.PolygonMode == polygonLine {
+		gl.PolygonMode(gl.FRONT_AND_BACK, gl.LINE)
+	} else {
+		gl.PolygonMode(gl.FRONT_AND_BACK, gl.FILL)
+	}
+
+	if rs.OffsetFactor != 0 || rs.OffsetUnits != 0 {
+		gl.Enable(gl.POLYGON_OFFSET_FILL)
+		gl.Enable(gl.POLYGON_OFFSET_LINE)
+		gl.PolygonOffset(rs.OffsetFactor, rs.OffsetUnits)
+	} else {
+		gl.Disable(gl.POLYGON_OFFSET_FILL)
+		gl.Disable(gl.POLYGON_OFFSET_LINE)
+	}
+}
+
+// applyPragmas sets the state declared in a shader source, e.g. "#pragma blend alpha", "#pragma cull none",
+// "#pragma ztest lequal", "#pragma zwrite off", "#pragma polygon line" or "#pragma offset -1 -1".
+// ztest also accepts off to disable the depth test.
+func (rs *renderState) applyPragmas(source string) error {
+	for number, line := range strings.Split(source, "\n") {
+		words := strings.Fields(line)
+		if len(words) < 2 || words[0] != "#pragma" {
+			continue
+		}
+
+		var err error
+		switch words[1] {
+		case "blend":
+			err = pragmaChoice(words, blendModes, &rs.Blend)
+		case "cull":
+			err = pragmaChoice(words, cullModes, &rs.Cull)
+		case "polygon":
+			err = pragmaChoice(words, polygonModes, &rs.PolygonMode)
+		case "ztest":
+			if len(words) == 3 && words[2] == "off" {
+				rs.DepthTest = false
+			} else if err = pragmaChoice(words, depthFuncs, &rs.DepthFunc); err == nil {
+				rs.DepthTest = true
+			}
+		case "zwrite":
+			var value string
+			if err = pragmaChoice(words, []string{"on", "off"}, &value); err == nil {
+				rs.DepthWrite = value == "on"
+			}
+		case "offset":
+			err = pragmaOffset(words, rs)
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %v", number+1, err)
+		}
+	}
+	return nil
+}
+
+// pragmaChoice sets value to the single argument of a pragma if it is one of the options
+func pragmaChoice(words []string, options []string, value *string) error {
+	if len(words) == 3 {
+		for _, option := range options {
+			if words[2] == option {
+				*value = option
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("#pragma %s expects one of %s", words[1], strings.Join(options, ", "))
+}
+
+func pragmaOffset(words []string, rs *renderState) error {
+	if len(words) == 4 {
+		factor, factorErr := strconv.ParseFloat(words[2], 32)
+		units, unitsErr := strconv.ParseFloat(words[3], 32)
+		if factorErr == nil && unitsErr == nil {
+			rs.OffsetFactor, rs.OffsetUnits = float32(factor), float32(units)
+			return nil
+		}
+	}
+	return fmt.Errorf("#pragma offset expects a factor and units")
+}
+
+// drawComboString draws a combo box choosing value among the options
+func drawComboString(label string, value *string, options []string) bool {
+	changed := false
+	if imgui.BeginCombo(label, *value) {
+		for _, option := range options {
+			if imgui.SelectableV(option, option == *value, 0, imgui.Vec2{}) {
+				*value = option
+				changed = true
+			}
+		}
+		imgui.EndCombo()
+	}
+	return changed
+}
+
+// drawUI draws the render state editors. The state is applied on the next draw call.
+func (rs *renderState) drawUI() {
+	drawComboString("Blend", &rs.Blend, blendModes)
+	drawComboString("Cull", &rs.Cull, cullModes)
+	imgui.Checkbox("Depth test", &rs.DepthTest)
+	imgui.SameLine()
+	imgui.Checkbox("Depth write", &rs.DepthWrite)
+	drawComboString("Depth func", &rs.DepthFunc, depthFuncs)
+	drawComboString("Polygon mode", &rs.PolygonMode, polygonModes)
+	imgui.DragFloat("Offset factor", &rs.OffsetFactor)
+	imgui.DragFloat("Offset units", &rs.OffsetUnits)
+}
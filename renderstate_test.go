@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestRenderStatePragmas(t *testing.T) {
+	source := `#version 330
+	#pragma multi_compile USE_MASK
+	#pragma blend premultiplied
+	#pragma cull none
+	#pragma ztest lequal
+	#pragma zwrite off
+	#pragma polygon line
+	#pragma offset -1 -2.5
+	void main() {}`
+
+	rs := defaultRenderState()
+	assert.NilError(t, rs.applyPragmas(source))
+	assert.Equal(t, rs, renderState{
+		Blend:        blendPremultiplied,
+		DepthTest:    true,
+		DepthWrite:   false,
+		DepthFunc:    depthLessEqual,
+		Cull:         cullNone,
+		PolygonMode:  polygonLine,
+		OffsetFactor: -1,
+		OffsetUnits:  -2.5,
+	})
+
+	rs = defaultRenderState()
+	assert.NilError(t, rs.applyPragmas("#pragma ztest off"))
+	assert.Equal(t, rs.DepthTest, false)
+	assert.Equal(t, rs.DepthFunc, depthLess)
+
+	assert.ErrorContains(t, rs.applyPragmas("\n#pragma blend multiply"), "line 2: #pragma blend expects one of opaque, alpha, additive, premultiplied")
+	assert.ErrorContains(t, rs.applyPragmas("#pragma offset 1"), "expects a factor and units")
+	assert.ErrorContains(t, rs.applyPragmas("#pragma zwrite"), "expects one of on, off")
+}
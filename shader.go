@@ -3,8 +3,12 @@ package main
 import (
 	"fmt"
 	"io/ioutil"
+	"log"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/go-gl/gl/v3.2-core/gl"
 )
@@ -18,32 +22,110 @@ const (
 	uniformVec4  uniformType = "vec4"
 	uniformTex2D uniformType = "sampler2D"
 	uniformMat4  uniformType = "mat4"
+	uniformMat3  uniformType = "mat3"
+	uniformInt   uniformType = "int"
+	uniformIVec2 uniformType = "ivec2"
+	uniformIVec3 uniformType = "ivec3"
+	uniformIVec4 uniformType = "ivec4"
+	uniformBool  uniformType = "bool"
+	// uniformTexCube is a samplerCube, e.g. the environment of a reflection shader
+	uniformTexCube uniformType = "samplerCube"
 )
 
+// isTexture tells if the uniform is a sampler, which is set from an image file instead of a value
+func (t uniformType) isTexture() bool {
+	return t == uniformTex2D || t == uniformTexCube
+}
+
+const (
+	camWorldPosName    string = "cameraWorldPos"
+	modelMatrixName    string = "modelMatrix"
+	viewMatrixName     string = "viewMatrix"
+	projMatrixName     string = "projMatrix"
+	mvpMatrixName      string = "MVP"
+	timeName           string = "time"
+	lightDirName       string = "lightDir"
+	lightColorName     string = "lightColor"
+	deltaTimeName      string = "deltaTime"
+	frameIndexName     string = "frameIndex"
+	resolutionName     string = "resolution"
+	mouseName          string = "mouse"
+	invModelMatrixName string = "invModelMatrix"
+	invViewMatrixName  string = "invViewMatrix"
+	invProjMatrixName  string = "invProjMatrix"
+	normalMatrixName   string = "normalMatrix"
+	cameraNearName     string = "cameraNear"
+	cameraFarName      string = "cameraFar"
+)
+
+// Vertex attribute names and the fixed locations they are bound to before linking,
+// so that every program (and every keyword variant) shares the same vertex layout.
 const (
-	camWorldPosName string = "cameraWorldPos"
-	modelMatrixName string = "modelMatrix"
-	viewMatrixName  string = "viewMatrix"
-	projMatrixName  string = "projMatrix"
-	mvpMatrixName   string = "MVP"
-	timeName        string = "time"
-	lightDirName    string = "lightDir"
-	lightColorName  string = "lightColor"
+	vertAttribName     string = "vert"
+	texCoordAttribName string = "vertTexCoord"
+	normalAttribName   string = "normal"
 )
 
+type vertexAttribute struct {
+	name     string
+	location uint32
+	size     int32
+	offset   int
+}
+
+// vertexAttributes describes the XYZUVN1N2N3 vertex layout used by renderer.setData
+var vertexAttributes = []vertexAttribute{
+	{vertAttribName, 0, 3, 0},
+	{texCoordAttribName, 1, 2, 3},
+	{normalAttribName, 2, 3, 5},
+}
+
+type shaderStage struct {
+	shaderType uint32
+	source     string
+}
+
 type shader struct {
 	program    uint32
 	vertSource string
 	fragSource string
+	geomSource string
 	uniforms   []uniform
+	keywords   []string
+	variants   map[string]uint32
+	builtins   []builtinUniform
+
+	// The files the sources were loaded from, glslPath is set for single-file shaders instead
+	vertPath string
+	fragPath string
+	geomPath string
+	glslPath string
+
+	// renderState is the default state of the materials, declared with render state pragmas
+	renderState renderState
 }
 
 type uniform struct {
-	uType uniformType
-	name  string
+	uType      uniformType
+	name       string
+	annotation uniformAnnotation
 }
 
-func appendUniforms(uniforms []uniform, source string) {
+// uniformAnnotation holds the editor hints parsed from the comment trailing a material field,
+// e.g. "float specPower; // @range(1,256) @default(32) @tooltip("Shininess")"
+type uniformAnnotation struct {
+	hasRange     bool
+	min          float32
+	max          float32
+	defaultValue []float32
+	tooltip      string
+	color        bool
+	// hdr colors may exceed 1 and get an intensity control
+	hdr bool
+}
+
+func getUniforms(source string) []uniform {
+	uniforms := make([]uniform, 0)
 
 	lines := strings.Split(source, "\n")
 	startMaterialStruct := false
@@ -54,18 +136,36 @@ func appendUniforms(uniforms []uniform, source string) {
 		}
 
 		if startMaterialStruct {
-			words := strings.Split(strings.Trim(line, " "), " ")
-			if strings.Contains(line, "};") {
+			// Split the declaration from the trailing annotation comment
+			declaration := line
+			comment := ""
+			if commentStart := strings.Index(line, "//"); commentStart >= 0 {
+				declaration = line[:commentStart]
+				comment = line[commentStart+2:]
+			}
+
+			if strings.Contains(declaration, "};") {
 				break
 			}
 
-			uType, error := getUniformTypeFromString(strings.TrimSpace(words[0]))
+			words := strings.Fields(strings.Replace(declaration, ";", " ", -1))
+			if len(words) < 2 {
+				continue
+			}
+
+			uType, error := getUniformTypeFromString(words[0])
+			if error != nil {
+				fmt.Println(error.Error())
+				continue
+			}
+
+			annotation, error := parseUniformAnnotation(comment)
 			if error != nil {
 				fmt.Println(error.Error())
 			}
 
-			name := strings.TrimSpace(strings.Replace(words[1], ";", "", -1))
-			u := uniform{uType, name}
+			name := words[1]
+			u := uniform{uType, name, annotation}
 
 			alreadyAdded := false
 			for _, uniform := range uniforms {
@@ -79,46 +179,127 @@ func appendUniforms(uniforms []uniform, source string) {
 			}
 		}
 	}
+	return uniforms
 }
 
-func getUniforms(source string) []uniform {
-	uniforms := make([]uniform, 0)
+// parseUniformAnnotation parses the @range, @default, @tooltip, @color and @hdr annotations of a field comment.
+// An annotation starts a word, so an @ inside a word such as an email address is plain text. Unknown and
+// bad annotations are skipped, the first bad one is returned as the error.
+func parseUniformAnnotation(comment string) (uniformAnnotation, error) {
+	var annotation uniformAnnotation
+	var firstErr error
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
 
-	lines := strings.Split(source, "\n")
-	startMaterialStruct := false
-	for _, line := range lines {
-		if !startMaterialStruct && (strings.Contains(line, "struct Material") || strings.Contains(line, "struct material")) {
-			startMaterialStruct = true
+	for i := 0; i < len(comment); i++ {
+		if comment[i] != '@' || (i > 0 && !unicode.IsSpace(rune(comment[i-1]))) {
 			continue
 		}
 
-		if startMaterialStruct {
-			words := strings.Split(strings.Trim(line, " "), " ")
-			if strings.Contains(line, "};") {
+		// Read the annotation keyword
+		start := i + 1
+		end := start
+		for end < len(comment) && isIdentChar(comment[end]) {
+			end++
+		}
+		keyword := comment[start:end]
+		if keyword == "" {
+			continue
+		}
+
+		// Read the optional argument list
+		var args []string
+		if end < len(comment) && comment[end] == '(' {
+			var err error
+			args, end, err = parseAnnotationArgs(comment, end+1)
+			if err != nil {
+				fail(fmt.Errorf("Bad annotation @%s: %v", keyword, err))
 				break
 			}
-
-			uType, error := getUniformTypeFromString(strings.TrimSpace(words[0]))
-			if error != nil {
-				fmt.Println(error.Error())
+		}
+		i = end - 1
+
+		switch keyword {
+		case "range":
+			values, err := parseAnnotationFloats(args)
+			if err != nil || len(values) != 2 {
+				fail(fmt.Errorf("Bad annotation @range: expected two numbers, got %q", strings.Join(args, ",")))
+				continue
+			}
+			annotation.hasRange = true
+			annotation.min = values[0]
+			annotation.max = values[1]
+		case "default":
+			values, err := parseAnnotationFloats(args)
+			if err != nil || len(values) == 0 {
+				fail(fmt.Errorf("Bad annotation @default: expected numbers, got %q", strings.Join(args, ",")))
+				continue
 			}
+			annotation.defaultValue = values
+		case "tooltip":
+			if len(args) != 1 {
+				fail(fmt.Errorf("Bad annotation @tooltip: expected one string"))
+				continue
+			}
+			annotation.tooltip = args[0]
+		case "color":
+			annotation.color = true
+		case "hdr":
+			annotation.color = true
+			annotation.hdr = true
+		default:
+			log.Printf("WARNING: unsupported shader annotation @%s is ignored", keyword)
+		}
+	}
 
-			name := strings.TrimSpace(strings.Replace(words[1], ";", "", -1))
-			u := uniform{uType, name}
+	return annotation, firstErr
+}
 
-			alreadyAdded := false
-			for _, uniform := range uniforms {
-				if uniform.name == name {
-					alreadyAdded = true
-				}
-			}
+// parseAnnotationArgs reads a comma separated argument list up to the closing parenthesis.
+// Quoted arguments may contain commas and parentheses. Returns the index after the closing parenthesis.
+func parseAnnotationArgs(comment string, start int) ([]string, int, error) {
+	var args []string
+	var current strings.Builder
+	inQuotes := false
+
+	for i := start; i < len(comment); i++ {
+		c := comment[i]
+		switch {
+		case c == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+			current.WriteByte(c)
+		case c == ',':
+			args = append(args, strings.TrimSpace(current.String()))
+			current.Reset()
+		case c == ')':
+			args = append(args, strings.TrimSpace(current.String()))
+			return args, i + 1, nil
+		default:
+			current.WriteByte(c)
+		}
+	}
 
-			if !alreadyAdded {
-				uniforms = append(uniforms, u)
-			}
+	return nil, len(comment), fmt.Errorf("missing closing parenthesis")
+}
+
+func parseAnnotationFloats(args []string) ([]float32, error) {
+	values := make([]float32, 0, len(args))
+	for _, arg := range args {
+		value, err := strconv.ParseFloat(arg, 32)
+		if err != nil {
+			return nil, err
 		}
+		values = append(values, float32(value))
 	}
-	return uniforms
+	return values, nil
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
 }
 
 // GetUniformTypeFromString Get the uniform type form a shader word
@@ -134,63 +315,320 @@ func getUniformTypeFromString(word string) (uniformType, error) {
 		return uniformVec4, nil
 	case "sampler2D":
 		return uniformTex2D, nil
+	case "samplerCube":
+		return uniformTexCube, nil
+	case "mat3":
+		return uniformMat3, nil
 	case "mat4":
 		return uniformMat4, nil
+	case "int":
+		return uniformInt, nil
+	case "ivec2":
+		return uniformIVec2, nil
+	case "ivec3":
+		return uniformIVec3, nil
+	case "ivec4":
+		return uniformIVec4, nil
+	case "bool":
+		return uniformBool, nil
 	default:
 		return "", fmt.Errorf("Unsupported shader uniform: %s", word)
 	}
 }
 
-func (s *shader) loadFromFile(vertSource string, fragSource string) error {
-	vertFile, errV := os.Open(vertSource)
-	fragFile, errF := os.Open(fragSource)
-	defer vertFile.Close()
-	defer fragFile.Close()
+// getKeywords returns the keywords declared with "#pragma multi_compile KEYWORD_A KEYWORD_B"
+func getKeywords(source string) []string {
+	keywords := make([]string, 0)
+
+	for _, line := range strings.Split(source, "\n") {
+		words := strings.Fields(line)
+		if len(words) < 2 || words[0] != "#pragma" || words[1] != "multi_compile" {
+			continue
+		}
+
+		for _, keyword := range words[2:] {
+			alreadyAdded := false
+			for _, k := range keywords {
+				if k == keyword {
+					alreadyAdded = true
+				}
+			}
+			if !alreadyAdded {
+				keywords = append(keywords, keyword)
+			}
+		}
+	}
+	return keywords
+}
+
+// injectDefines inserts a #define for every keyword directly after the #version directive.
+// A #line directive restores the original numbering so compile errors point at the file lines.
+func injectDefines(source string, keywords []string) string {
+	if len(keywords) == 0 {
+		return source
+	}
 
-	if errV != nil {
-		return errV
+	lines := strings.Split(source, "\n")
+	versionLine := -1
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "#version") {
+			versionLine = i
+			break
+		}
 	}
-	if errF != nil {
-		return errF
+
+	var defines strings.Builder
+	for _, keyword := range keywords {
+		defines.WriteString("#define " + keyword + "\n")
 	}
+	// Before GLSL 4.20 "#line n" numbers the following line n+1
+	defines.WriteString(fmt.Sprintf("#line %d", versionLine+1))
+
+	injected := make([]string, 0, len(lines)+1)
+	injected = append(injected, lines[:versionLine+1]...)
+	injected = append(injected, defines.String())
+	injected = append(injected, lines[versionLine+1:]...)
+	return strings.Join(injected, "\n")
+}
 
-	vertBytes, errV := ioutil.ReadAll(vertFile)
-	fragBytes, errF := ioutil.ReadAll(fragFile)
+// variantKey returns the cache key of a keyword combination, independent of keyword order
+func variantKey(keywords []string) string {
+	sorted := make([]string, len(keywords))
+	copy(sorted, keywords)
+	sort.Strings(sorted)
+	return strings.Join(sorted, " ")
+}
 
-	s.vertSource = string(vertBytes) + "\x00"
-	s.fragSource = string(fragBytes) + "\x00"
+// variant returns the program compiled with the given keywords defined, compiling and caching it on first use
+func (s *shader) variant(keywords []string) (uint32, error) {
+	key := variantKey(keywords)
+	if program, ok := s.variants[key]; ok {
+		return program, nil
+	}
+
+	program, err := newProgram(s.stages(keywords)...)
+	if err != nil {
+		return 0, err
+	}
+
+	s.variants[key] = program
+	return program, nil
+}
+
+// delete releases the programs of every compiled variant
+func (s *shader) delete() {
+	for _, program := range s.variants {
+		delete(uniformTables, program)
+		gl.DeleteProgram(program)
+	}
+	s.variants = nil
+	s.program = 0
+}
+
+// stages returns the shader stages with the given keywords defined. The geometry stage is optional.
+func (s *shader) stages(keywords []string) []shaderStage {
+	stages := []shaderStage{{gl.VERTEX_SHADER, injectDefines(s.vertSource, keywords)}}
+	if s.geomSource != "" {
+		stages = append(stages, shaderStage{gl.GEOMETRY_SHADER, injectDefines(s.geomSource, keywords)})
+	}
+	stages = append(stages, shaderStage{gl.FRAGMENT_SHADER, injectDefines(s.fragSource, keywords)})
+	return stages
+}
+
+// sources returns the source of every stage in pipeline order
+func (s *shader) sources() []string {
+	sources := []string{s.vertSource}
+	if s.geomSource != "" {
+		sources = append(sources, s.geomSource)
+	}
+	return append(sources, s.fragSource)
+}
+
+// loadFromFile loads and compiles a vertex, fragment and optional geometry shader. Pass an empty geomPath to skip the geometry stage.
+func (s *shader) loadFromFile(vertPath string, fragPath string, geomPath string) error {
+	var err error
+	if s.vertSource, err = readShaderSource(vertPath); err != nil {
+		return err
+	}
+	if s.fragSource, err = readShaderSource(fragPath); err != nil {
+		return err
+	}
+
+	s.geomSource = ""
+	if geomPath != "" {
+		if s.geomSource, err = readShaderSource(geomPath); err != nil {
+			return err
+		}
+	}
+
+	s.vertPath, s.fragPath, s.geomPath, s.glslPath = vertPath, fragPath, geomPath, ""
+	return s.build()
+}
+
+// loadFromGLSLFile loads and compiles a single-file shader where "#pragma stage vertex",
+// "#pragma stage geometry" and "#pragma stage fragment" start the sections of each stage.
+func (s *shader) loadFromGLSLFile(filePath string) error {
+	source, err := readShaderSource(filePath)
+	if err != nil {
+		return err
+	}
+
+	stageSources, err := splitStages(strings.TrimSuffix(source, "\x00"))
+	if err != nil {
+		return fmt.Errorf("%s: %v", filePath, err)
+	}
+
+	s.vertSource = stageSources[gl.VERTEX_SHADER] + "\x00"
+	s.fragSource = stageSources[gl.FRAGMENT_SHADER] + "\x00"
+	s.geomSource = ""
+	if geomSource, ok := stageSources[gl.GEOMETRY_SHADER]; ok {
+		s.geomSource = geomSource + "\x00"
+	}
+
+	s.vertPath, s.fragPath, s.geomPath, s.glslPath = "", "", "", filePath
+	return s.build()
+}
+
+// splitStages splits a single-file shader into the source of each stage, keyed by GL shader type.
+// Lines before the first stage pragma are shared by all stages. The lines of the other stages are
+// blanked rather than removed, so compile errors report the line numbers of the original file.
+func splitStages(source string) (map[uint32]string, error) {
+	const (
+		sharedLine = uint32(0)
+		pragmaLine = ^uint32(0)
+	)
+
+	lines := strings.Split(source, "\n")
+	lineStages := make([]uint32, len(lines))
+	stagesFound := make(map[uint32]bool)
+
+	currentStage := sharedLine
+	for i, line := range lines {
+		words := strings.Fields(line)
+		if len(words) >= 2 && words[0] == "#pragma" && words[1] == "stage" {
+			if len(words) < 3 {
+				return nil, fmt.Errorf("line %d: missing stage name", i+1)
+			}
+
+			switch words[2] {
+			case "vertex":
+				currentStage = gl.VERTEX_SHADER
+			case "geometry":
+				currentStage = gl.GEOMETRY_SHADER
+			case "fragment":
+				currentStage = gl.FRAGMENT_SHADER
+			default:
+				return nil, fmt.Errorf("line %d: unknown shader stage %q", i+1, words[2])
+			}
+
+			if stagesFound[currentStage] {
+				return nil, fmt.Errorf("line %d: %s stage declared twice", i+1, words[2])
+			}
+			stagesFound[currentStage] = true
+
+			// The pragma line itself is blanked in every stage
+			lineStages[i] = pragmaLine
+			continue
+		}
+		lineStages[i] = currentStage
+	}
+
+	if !stagesFound[gl.VERTEX_SHADER] || !stagesFound[gl.FRAGMENT_SHADER] {
+		return nil, fmt.Errorf("a vertex and a fragment stage are required")
+	}
+
+	stageSources := make(map[uint32]string)
+	for stage := range stagesFound {
+		stageLines := make([]string, len(lines))
+		for i, line := range lines {
+			if lineStages[i] == sharedLine || lineStages[i] == stage {
+				stageLines[i] = line
+			}
+		}
+		stageSources[stage] = strings.Join(stageLines, "\n")
+	}
+
+	return stageSources, nil
+}
+
+func readShaderSource(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	bytes, err := ioutil.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+
+	return string(bytes) + "\x00", nil
+}
+
+// build compiles the loaded sources and parses their render state, keywords and material uniforms
+func (s *shader) build() error {
+	s.renderState = defaultRenderState()
+	for _, source := range s.sources() {
+		if err := s.renderState.applyPragmas(source); err != nil {
+			return fmt.Errorf("render state: %v", err)
+		}
+	}
 
 	var compileErr error
-	s.program, compileErr = newProgram(s.vertSource, s.fragSource)
+	s.program, compileErr = newProgram(s.stages(nil)...)
 
 	if compileErr != nil {
 		return compileErr
 	}
 
-	//appendUniforms(s.uniforms, s.vertSource)
-	//appendUniforms(s.uniforms, s.fragSource)
-	vertUniforms := getUniforms(s.vertSource)
-	fragUniforms := getUniforms(s.fragSource)
+	s.variants = map[string]uint32{variantKey(nil): s.program}
+	s.builtins = consumedBuiltins(s.sources())
+	s.keywords = make([]string, 0)
+	s.uniforms = make([]uniform, 0)
 
-	for _, vertUniform := range vertUniforms {
-		s.uniforms = append(s.uniforms, vertUniform)
-	}
-
-	for _, fragUniform := range fragUniforms {
-		alreadyAdded := false
-		for _, uniform := range s.uniforms {
-			if uniform.name == fragUniform.name {
-				alreadyAdded = true
+	for _, source := range s.sources() {
+		for _, keyword := range getKeywords(source) {
+			alreadyAdded := false
+			for _, k := range s.keywords {
+				if k == keyword {
+					alreadyAdded = true
+				}
+			}
+			if !alreadyAdded {
+				s.keywords = append(s.keywords, keyword)
 			}
 		}
-		if !alreadyAdded {
-			s.uniforms = append(s.uniforms, fragUniform)
+
+		for _, stageUniform := range getUniforms(source) {
+			alreadyAdded := false
+			for _, uniform := range s.uniforms {
+				if uniform.name == stageUniform.name {
+					alreadyAdded = true
+				}
+			}
+			if !alreadyAdded {
+				s.uniforms = append(s.uniforms, stageUniform)
+			}
 		}
 	}
 
 	return nil
 }
 
+func stageName(shaderType uint32) string {
+	switch shaderType {
+	case gl.VERTEX_SHADER:
+		return "vertex"
+	case gl.GEOMETRY_SHADER:
+		return "geometry"
+	case gl.FRAGMENT_SHADER:
+		return "fragment"
+	default:
+		return "unknown"
+	}
+}
+
 func compileShader(source string, shaderType uint32) (uint32, error) {
 	shader := gl.CreateShader(shaderType)
 
@@ -207,30 +645,48 @@ func compileShader(source string, shaderType uint32) (uint32, error) {
 
 		log := strings.Repeat("\x00", int(logLength+1))
 		gl.GetShaderInfoLog(shader, logLength, nil, gl.Str(log))
+		gl.DeleteShader(shader)
 
-		return 0, fmt.Errorf("failed to compile %v: %v", source, log)
+		return 0, fmt.Errorf("failed to compile %s shader: %v", stageName(shaderType), log)
 	}
 
 	return shader, nil
 }
 
-func newProgram(vertexShaderSource, fragmentShaderSource string) (uint32, error) {
-	vertexShader, err := compileShader(vertexShaderSource, gl.VERTEX_SHADER)
-	if err != nil {
-		return 0, err
+// newProgram compiles and links the given stages. Every created GL object is released again on failure.
+func newProgram(stages ...shaderStage) (uint32, error) {
+	shaders := make([]uint32, 0, len(stages))
+	deleteShaders := func() {
+		for _, shader := range shaders {
+			gl.DeleteShader(shader)
+		}
 	}
 
-	fragmentShader, err := compileShader(fragmentShaderSource, gl.FRAGMENT_SHADER)
-	if err != nil {
-		return 0, err
+	for _, stage := range stages {
+		shader, err := compileShader(stage.source, stage.shaderType)
+		if err != nil {
+			deleteShaders()
+			return 0, err
+		}
+		shaders = append(shaders, shader)
 	}
 
 	program := gl.CreateProgram()
 
-	gl.AttachShader(program, vertexShader)
-	gl.AttachShader(program, fragmentShader)
+	for _, shader := range shaders {
+		gl.AttachShader(program, shader)
+	}
+	for _, attrib := range vertexAttributes {
+		gl.BindAttribLocation(program, attrib.location, gl.Str(attrib.name+"\x00"))
+	}
 	gl.LinkProgram(program)
 
+	// The linked program keeps its own copy of the binaries
+	for _, shader := range shaders {
+		gl.DetachShader(program, shader)
+	}
+	deleteShaders()
+
 	var status int32
 	gl.GetProgramiv(program, gl.LINK_STATUS, &status)
 	if status == gl.FALSE {
@@ -239,12 +695,13 @@ func newProgram(vertexShaderSource, fragmentShaderSource string) (uint32, error)
 
 		log := strings.Repeat("\x00", int(logLength+1))
 		gl.GetProgramInfoLog(program, logLength, nil, gl.Str(log))
+		gl.DeleteProgram(program)
 
 		return 0, fmt.Errorf("failed to link program: %v", log)
 	}
 
-	gl.DeleteShader(vertexShader)
-	gl.DeleteShader(fragmentShader)
+	bindGlobalsBlock(program)
+	uniformTables[program] = newUniformTable(program)
 
 	return program, nil
 }
@@ -1,19 +1,21 @@
 package main
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/go-gl/gl/v3.2-core/gl"
 	"gotest.tools/assert"
 )
 
 func TestGetShaderUniforms(t *testing.T) {
 
 	var expectedUniforms []uniform
-	testUniformFloat := uniform{uniformFloat, "testFloat"}
-	testUniformVec2 := uniform{uniformVec2, "testVec2"}
-	testUniformVec3 := uniform{uniformVec3, "testVec3"}
-	testUniformVec4 := uniform{uniformVec4, "testVec4"}
-	testUniformTex2D := uniform{uniformTex2D, "testTex2D"}
+	testUniformFloat := uniform{uType: uniformFloat, name: "testFloat"}
+	testUniformVec2 := uniform{uType: uniformVec2, name: "testVec2"}
+	testUniformVec3 := uniform{uType: uniformVec3, name: "testVec3"}
+	testUniformVec4 := uniform{uType: uniformVec4, name: "testVec4"}
+	testUniformTex2D := uniform{uType: uniformTex2D, name: "testTex2D"}
 
 	expectedUniforms = append(expectedUniforms,
 		testUniformFloat,
@@ -51,3 +53,102 @@ func TestGetShaderUniforms(t *testing.T) {
 	}
 
 }
+
+func TestGetShaderUniformAnnotations(t *testing.T) {
+
+	testShader := `#version 330
+	struct Material {
+		vec3 color; // @color @default(0.8, 0.3, 0.2)
+		float specPower; // @range(1,256) @default(32) @tooltip("Shininess, (higher is sharper)")
+
+		float plain;
+	};`
+
+	shaderUniforms := getUniforms(testShader)
+	assert.Equal(t, len(shaderUniforms), 3, "Invalid number of shader material fields parsed.")
+
+	color := shaderUniforms[0].annotation
+	assert.Equal(t, color.color, true, "Missing @color annotation")
+	assert.DeepEqual(t, color.defaultValue, []float32{0.8, 0.3, 0.2})
+
+	specPower := shaderUniforms[1].annotation
+	assert.Equal(t, specPower.hasRange, true, "Missing @range annotation")
+	assert.Equal(t, specPower.min, float32(1))
+	assert.Equal(t, specPower.max, float32(256))
+	assert.DeepEqual(t, specPower.defaultValue, []float32{32})
+	assert.Equal(t, specPower.tooltip, "Shininess, (higher is sharper)")
+
+	plain := shaderUniforms[2].annotation
+	assert.Equal(t, plain.hasRange, false)
+	assert.Equal(t, plain.color, false)
+	assert.Equal(t, len(plain.defaultValue), 0)
+
+	_, err := parseUniformAnnotation(" @range(1)")
+	assert.ErrorContains(t, err, "@range")
+
+	// An @ inside a word is plain text and unknown annotations don't stop the ones after them
+	annotation, err := parseUniformAnnotation(" see foo@bar, @unknown @range(0, 2) @default(1)")
+	assert.NilError(t, err)
+	assert.Equal(t, annotation.hasRange, true)
+	assert.Equal(t, annotation.max, float32(2))
+	assert.DeepEqual(t, annotation.defaultValue, []float32{1})
+
+	annotation, err = parseUniformAnnotation(" @range(1) @default(4)")
+	assert.ErrorContains(t, err, "@range")
+	assert.DeepEqual(t, annotation.defaultValue, []float32{4})
+}
+
+func TestShaderKeywords(t *testing.T) {
+
+	testShader := "#version 330\n#pragma multi_compile SPECULAR RIM\n#pragma multi_compile RIM\nout vec4 outputColor;"
+
+	keywords := getKeywords(testShader)
+	assert.DeepEqual(t, keywords, []string{"SPECULAR", "RIM"})
+
+	injected := injectDefines(testShader, []string{"RIM"})
+	expected := "#version 330\n#define RIM\n#line 1\n#pragma multi_compile SPECULAR RIM\n#pragma multi_compile RIM\nout vec4 outputColor;"
+	assert.Equal(t, injected, expected, "Defines must follow the #version directive")
+
+	assert.Equal(t, injectDefines(testShader, nil), testShader, "No keywords must leave the source untouched")
+	assert.Equal(t, variantKey([]string{"SPECULAR", "RIM"}), variantKey([]string{"RIM", "SPECULAR"}), "Variant key must not depend on order")
+}
+
+func TestSplitStages(t *testing.T) {
+
+	testShader := `#version 330
+struct Material {
+    vec3 color;
+};
+uniform Material material;
+#pragma stage vertex
+in vec3 vert;
+void main() { gl_Position = vec4(vert, 1); }
+#pragma stage fragment
+out vec4 outputColor;
+void main() { outputColor = vec4(material.color, 1); }`
+
+	stages, err := splitStages(testShader)
+	assert.NilError(t, err)
+	assert.Equal(t, len(stages), 2, "Invalid number of stages")
+
+	vertLines := strings.Split(stages[gl.VERTEX_SHADER], "\n")
+	fragLines := strings.Split(stages[gl.FRAGMENT_SHADER], "\n")
+	assert.Equal(t, len(vertLines), 11, "Line count must be preserved")
+	assert.Equal(t, len(fragLines), 11, "Line count must be preserved")
+
+	assert.Equal(t, vertLines[0], "#version 330", "Shared lines must be kept in every stage")
+	assert.Equal(t, fragLines[2], "    vec3 color;", "Shared lines must be kept in every stage")
+	assert.Equal(t, vertLines[6], "in vec3 vert;")
+	assert.Equal(t, vertLines[9], "", "Other stages must be blanked")
+	assert.Equal(t, fragLines[6], "", "Other stages must be blanked")
+	assert.Equal(t, fragLines[8], "", "Stage pragmas must be blanked")
+	assert.Equal(t, fragLines[9], "out vec4 outputColor;")
+
+	assert.Equal(t, len(getUniforms(stages[gl.FRAGMENT_SHADER])), 1, "Shared material struct must be parsed")
+
+	_, err = splitStages("#version 330\n#pragma stage vertex\nvoid main() {}")
+	assert.ErrorContains(t, err, "fragment")
+
+	_, err = splitStages("#version 330\n#pragma stage tessellation\n")
+	assert.ErrorContains(t, err, "line 2")
+}
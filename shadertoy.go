@@ -0,0 +1,165 @@
+package main
+
+import (
+	"log"
+
+	"github.com/go-gl/gl/v3.2-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/inkyblackness/imgui-go"
+)
+
+// Shadertoy compatible uniform names
+const (
+	iTimeName       string = "iTime"
+	iTimeDeltaName  string = "iTimeDelta"
+	iFrameName      string = "iFrame"
+	iResolutionName string = "iResolution"
+	iMouseName      string = "iMouse"
+)
+
+// shadertoyVertSource draws a fullscreen triangle from the vertex index, no vertex buffer is needed
+const shadertoyVertSource = `#version 330
+void main() {
+    vec2 position = vec2((gl_VertexID << 1) & 2, gl_VertexID & 2);
+    gl_Position = vec4(position * 2.0 - 1.0, 0, 1);
+}
+` + "\x00"
+
+// shadertoyFragHeader declares the Shadertoy inputs. The channels are material fields,
+// so they show up as texture fields in the GUI.
+const shadertoyFragHeader = `#version 330
+struct Material {
+    sampler2D iChannel0;
+    sampler2D iChannel1;
+    sampler2D iChannel2;
+    sampler2D iChannel3;
+};
+
+uniform Material material;
+
+#define iChannel0 material.iChannel0
+#define iChannel1 material.iChannel1
+#define iChannel2 material.iChannel2
+#define iChannel3 material.iChannel3
+
+uniform float iTime;
+uniform float iTimeDelta;
+uniform int iFrame;
+uniform vec3 iResolution;
+uniform vec4 iMouse;
+
+out vec4 outputColor;
+`
+
+// shadertoyFragFooter calls the pasted mainImage function
+const shadertoyFragFooter = `
+void main() {
+    mainImage(outputColor, gl_FragCoord.xy);
+}
+` + "\x00"
+
+const defaultShadertoySource = `void mainImage(out vec4 fragColor, in vec2 fragCoord) {
+    vec2 uv = fragCoord / iResolution.xy;
+    vec3 col = 0.5 + 0.5 * cos(iTime + uv.xyx + vec3(0, 2, 4));
+    fragColor = vec4(col, 1.0);
+}`
+
+type shadertoy struct {
+	enabled     bool
+	source      string
+	material    material
+	vao         uint32
+	shaderError error
+}
+
+func init() {
+	registerBuiltinUniform(iTimeName, uniformFloat, scopeFrame, func(ctx *objectContext) interface{} {
+		return GlobalRenderProps.Time
+	})
+	registerBuiltinUniform(iTimeDeltaName, uniformFloat, scopeFrame, func(ctx *objectContext) interface{} {
+		return GlobalRenderProps.DeltaTime
+	})
+	registerBuiltinUniform(iFrameName, uniformInt, scopeFrame, func(ctx *objectContext) interface{} {
+		return GlobalRenderProps.FrameIndex
+	})
+	registerBuiltinUniform(iResolutionName, uniformVec3, scopeFrame, func(ctx *objectContext) interface{} {
+		return mgl32.Vec3{GlobalRenderProps.Resolution[0], GlobalRenderProps.Resolution[1], 1}
+	})
+	registerBuiltinUniform(iMouseName, uniformVec4, scopeFrame, func(ctx *objectContext) interface{} {
+		// xy is the position while the button is held, zw the click position, negated once released
+		mouse := mgl32.Vec4{GlobalRenderProps.MouseDrag[0], GlobalRenderProps.MouseDrag[1], GlobalRenderProps.MouseClick[0], GlobalRenderProps.MouseClick[1]}
+		if !GlobalRenderProps.MouseDown {
+			mouse[2], mouse[3] = -mouse[2], -mouse[3]
+		}
+		return mouse
+	})
+}
+
+// shadertoyFragSource wraps a pasted mainImage function into a complete fragment shader.
+// The #line directive makes compile errors report the line numbers of the pasted code.
+func shadertoyFragSource(source string) string {
+	// Before GLSL 4.20 "#line n" numbers the following line n+1
+	return shadertoyFragHeader + "#line 0\n" + source + shadertoyFragFooter
+}
+
+// compile builds the program for the pasted source and a fresh material for its channels
+func (st *shadertoy) compile() error {
+	var newShader shader
+	newShader.vertSource = shadertoyVertSource
+	newShader.fragSource = shadertoyFragSource(st.source)
+
+	if err := newShader.build(); err != nil {
+		return err
+	}
+
+	var newMaterial material
+	newMaterial.init(newShader)
+	// The fullscreen triangle is drawn without depth test and culling
+	newMaterial.renderState.DepthTest = false
+	newMaterial.renderState.Cull = cullNone
+	st.material.release()
+	st.material = newMaterial
+
+	if st.vao == 0 {
+		// Core profile needs a bound vertex array even when no attributes are used
+		gl.GenVertexArrays(1, &st.vao)
+	}
+	return nil
+}
+
+// draw renders the fullscreen triangle
+func (st *shadertoy) draw() {
+	st.material.renderState.apply()
+
+	ApplyGlobalRenderProperties(st.material.shader.program)
+	gl.BindVertexArray(st.vao)
+	st.material.bindTextures()
+	gl.DrawArrays(gl.TRIANGLES, 0, 3)
+}
+
+// drawUI draws the source editor, the mode toggle and the channel fields
+func (st *shadertoy) drawUI() {
+	if imgui.Checkbox("Fullscreen Shadertoy mode", &st.enabled) && st.enabled && st.material.shader.program == 0 {
+		st.shaderError = st.compile()
+	}
+
+	imgui.Text("mainImage source")
+	imgui.InputTextMultilineV("##shadertoySource", &st.source, imgui.Vec2{X: -1, Y: 300}, imgui.InputTextFlagsAllowTabInput, nil)
+
+	if imgui.ButtonV("Compile##shadertoy", imgui.Vec2{X: 100, Y: 30}) {
+		st.shaderError = st.compile()
+		if st.shaderError != nil {
+			log.Printf("ERROR: " + st.shaderError.Error())
+		}
+	}
+
+	if st.shaderError != nil {
+		err := st.shaderError.Error()
+		imgui.InputTextMultiline("##shadertoyError", &err)
+	}
+
+	if len(st.material.fields) != 0 {
+		imgui.Text("Channels")
+		st.material.drawUI()
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"log"
+
+	"github.com/go-gl/gl/v3.2-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/inkyblackness/imgui-go"
+)
+
+// skyboxVertSource draws a fullscreen triangle on the far plane and passes the world space view
+// direction of each corner to the fragment shader
+const skyboxVertSource = `#version 330
+uniform mat4 invViewMatrix;
+uniform mat4 invProjMatrix;
+
+out vec3 viewDir;
+
+void main() {
+    vec2 position = vec2((gl_VertexID << 1) & 2, gl_VertexID & 2) * 2.0 - 1.0;
+    gl_Position = vec4(position, 1, 1);
+
+    vec4 eyeDir = invProjMatrix * vec4(position, 1, 1);
+    viewDir = mat3(invViewMatrix) * (eyeDir.xyz / eyeDir.w);
+}
+` + "\x00"
+
+// skyboxFragSource samples the cubemap. The cubemap is a material field, so it shows up in the GUI.
+const skyboxFragSource = `#version 330
+struct Material {
+    samplerCube sky; // @tooltip("A cross or panorama image, or six faces with * in place of px, nx, py, ny, pz and nz")
+    float exposure; // @range(0,8) @default(1)
+};
+
+uniform Material material;
+
+in vec3 viewDir;
+out vec4 outputColor;
+
+void main() {
+    outputColor = vec4(texture(material.sky, viewDir).rgb * material.exposure, 1);
+}
+` + "\x00"
+
+// defaultSkyboxPath is the cubemap the skybox starts with
+const defaultSkyboxPath = "Assets/sky.png"
+
+// skybox draws a cubemap behind the models instead of the clear color
+type skybox struct {
+	enabled     bool
+	material    material
+	vao         uint32
+	shaderError error
+}
+
+// compile builds the skybox program and a material holding the default cubemap
+func (sb *skybox) compile() error {
+	var newShader shader
+	newShader.vertSource = skyboxVertSource
+	newShader.fragSource = skyboxFragSource
+
+	if err := newShader.build(); err != nil {
+		return err
+	}
+
+	var newMaterial material
+	newMaterial.init(newShader)
+	// The sky is drawn first and never occludes the models
+	newMaterial.renderState.DepthTest = false
+	newMaterial.renderState.DepthWrite = false
+	newMaterial.renderState.Cull = cullNone
+	if sky := newMaterial.findField("sky"); sky != nil {
+		sky.setValue(fieldValue{Texture: defaultSkyboxPath})
+	}
+	newMaterial.activate()
+	sb.material.release()
+	sb.material = newMaterial
+
+	if sb.vao == 0 {
+		// Core profile needs a bound vertex array even when no attributes are used
+		gl.GenVertexArrays(1, &sb.vao)
+	}
+	return nil
+}
+
+// draw renders the sky as seen through the given camera. Only the camera rotation matters.
+func (sb *skybox) draw(view mgl32.Mat4, projection mgl32.Mat4) {
+	if sb.material.shader.program == 0 {
+		return
+	}
+	ctx := objectContext{mgl32.Ident4(), view, projection}
+
+	gl.UseProgram(sb.material.shader.program)
+	sb.material.renderState.apply()
+	sb.material.uniforms.applyBuiltins(scopeObject, &ctx)
+	gl.BindVertexArray(sb.vao)
+	sb.material.bindTextures()
+	gl.DrawArrays(gl.TRIANGLES, 0, 3)
+}
+
+// drawUI draws the skybox toggle and the cubemap and exposure fields
+func (sb *skybox) drawUI() {
+	if imgui.Checkbox("Skybox background", &sb.enabled) && sb.enabled && sb.material.shader.program == 0 {
+		sb.shaderError = sb.compile()
+		if sb.shaderError != nil {
+			log.Printf("ERROR: " + sb.shaderError.Error())
+		}
+	}
+
+	if sb.shaderError != nil {
+		imgui.Text("ERROR: " + sb.shaderError.Error())
+	}
+	if sb.enabled && len(sb.material.fields) != 0 {
+		sb.material.drawUI()
+	}
+}
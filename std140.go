@@ -0,0 +1,175 @@
+package main
+
+import (
+	"math"
+
+	"github.com/go-gl/mathgl/mgl32"
+)
+
+// std140Buffer packs values into a byte buffer following the std140 layout rules of uniform blocks:
+//   - scalars are aligned to 4 bytes and vec2 to 8 bytes
+//   - vec3 and vec4 are aligned to 16 bytes, a vec3 leaves room for a trailing scalar
+//   - array elements and matrix columns are aligned to 16 bytes with a stride rounded up to 16 bytes
+//   - structs start and end on a 16 byte boundary
+//
+// Every add function returns the byte offset the value was written at.
+type std140Buffer struct {
+	data []byte
+}
+
+const std140VecAlignment = 16
+
+// align pads the buffer with zeroes up to the next multiple of alignment
+func (b *std140Buffer) align(alignment int) {
+	if remainder := len(b.data) % alignment; remainder != 0 {
+		b.data = append(b.data, make([]byte, alignment-remainder)...)
+	}
+}
+
+// putUint32 appends a little endian 32 bit word
+func (b *std140Buffer) putUint32(value uint32) {
+	b.data = append(b.data, byte(value), byte(value>>8), byte(value>>16), byte(value>>24))
+}
+
+func (b *std140Buffer) putFloats(alignment int, values ...float32) int {
+	b.align(alignment)
+	offset := len(b.data)
+	for _, value := range values {
+		b.putUint32(math.Float32bits(value))
+	}
+	return offset
+}
+
+func (b *std140Buffer) addFloat(value float32) int {
+	return b.putFloats(4, value)
+}
+
+func (b *std140Buffer) addInt(value int32) int {
+	b.align(4)
+	offset := len(b.data)
+	b.putUint32(uint32(value))
+	return offset
+}
+
+func (b *std140Buffer) addBool(value bool) int {
+	if value {
+		return b.addInt(1)
+	}
+	return b.addInt(0)
+}
+
+func (b *std140Buffer) addVec2(value mgl32.Vec2) int {
+	return b.putFloats(8, value[0], value[1])
+}
+
+func (b *std140Buffer) addVec3(value mgl32.Vec3) int {
+	return b.putFloats(std140VecAlignment, value[0], value[1], value[2])
+}
+
+func (b *std140Buffer) addVec4(value mgl32.Vec4) int {
+	return b.putFloats(std140VecAlignment, value[0], value[1], value[2], value[3])
+}
+
+// addMat3 writes the three columns, each padded to a vec4
+func (b *std140Buffer) addMat3(value mgl32.Mat3) int {
+	offset := b.putFloats(std140VecAlignment, value[0], value[1], value[2], 0)
+	b.putFloats(std140VecAlignment, value[3], value[4], value[5], 0)
+	b.putFloats(std140VecAlignment, value[6], value[7], value[8], 0)
+	return offset
+}
+
+func (b *std140Buffer) addMat4(value mgl32.Mat4) int {
+	return b.putFloats(std140VecAlignment, value[:]...)
+}
+
+// addFloatArray writes every element with a 16 byte stride
+func (b *std140Buffer) addFloatArray(values []float32) int {
+	b.align(std140VecAlignment)
+	offset := len(b.data)
+	for _, value := range values {
+		b.putFloats(std140VecAlignment, value)
+	}
+	b.align(std140VecAlignment)
+	return offset
+}
+
+// addVec2Array writes every element with a 16 byte stride
+func (b *std140Buffer) addVec2Array(values []mgl32.Vec2) int {
+	b.align(std140VecAlignment)
+	offset := len(b.data)
+	for _, value := range values {
+		b.putFloats(std140VecAlignment, value[0], value[1])
+	}
+	b.align(std140VecAlignment)
+	return offset
+}
+
+// addVec3Array writes every element with a 16 byte stride
+func (b *std140Buffer) addVec3Array(values []mgl32.Vec3) int {
+	b.align(std140VecAlignment)
+	offset := len(b.data)
+	for _, value := range values {
+		b.putFloats(std140VecAlignment, value[0], value[1], value[2])
+	}
+	b.align(std140VecAlignment)
+	return offset
+}
+
+func (b *std140Buffer) addVec4Array(values []mgl32.Vec4) int {
+	b.align(std140VecAlignment)
+	offset := len(b.data)
+	for _, value := range values {
+		b.addVec4(value)
+	}
+	return offset
+}
+
+func (b *std140Buffer) addMat4Array(values []mgl32.Mat4) int {
+	b.align(std140VecAlignment)
+	offset := len(b.data)
+	for _, value := range values {
+		b.addMat4(value)
+	}
+	return offset
+}
+
+// beginStruct and endStruct align a nested struct member to 16 bytes
+func (b *std140Buffer) beginStruct() int {
+	b.align(std140VecAlignment)
+	return len(b.data)
+}
+
+func (b *std140Buffer) endStruct() {
+	b.align(std140VecAlignment)
+}
+
+// addValue writes a value returned by a built-in uniform provider
+func (b *std140Buffer) addValue(value interface{}) int {
+	switch v := value.(type) {
+	case float32:
+		return b.addFloat(v)
+	case int32:
+		return b.addInt(v)
+	case mgl32.Vec2:
+		return b.addVec2(v)
+	case mgl32.Vec3:
+		return b.addVec3(v)
+	case mgl32.Vec4:
+		return b.addVec4(v)
+	case mgl32.Mat3:
+		return b.addMat3(v)
+	case mgl32.Mat4:
+		return b.addMat4(v)
+	}
+	return len(b.data)
+}
+
+// bytes returns the packed data, padded to the 16 byte block size granularity
+func (b *std140Buffer) bytes() []byte {
+	b.align(std140VecAlignment)
+	return b.data
+}
+
+func (b *std140Buffer) reset() {
+	b.data = b.data[:0]
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/go-gl/gl/v3.2-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	"gotest.tools/assert"
+)
+
+func floatAt(data []byte, offset int) float32 {
+	bits := uint32(data[offset]) | uint32(data[offset+1])<<8 | uint32(data[offset+2])<<16 | uint32(data[offset+3])<<24
+	return math.Float32frombits(bits)
+}
+
+func TestStd140ScalarsAndVectors(t *testing.T) {
+	var buffer std140Buffer
+
+	assert.Equal(t, buffer.addFloat(1), 0)
+	assert.Equal(t, buffer.addVec2(mgl32.Vec2{2, 3}), 8, "vec2 must be aligned to 8 bytes")
+	assert.Equal(t, buffer.addVec3(mgl32.Vec3{4, 5, 6}), 16, "vec3 must be aligned to 16 bytes")
+	assert.Equal(t, buffer.addFloat(7), 28, "A scalar must fill the space after a vec3")
+	assert.Equal(t, buffer.addInt(8), 32)
+	assert.Equal(t, buffer.addVec4(mgl32.Vec4{9, 10, 11, 12}), 48, "vec4 must be aligned to 16 bytes")
+
+	data := buffer.bytes()
+	assert.Equal(t, len(data), 64)
+	assert.Equal(t, floatAt(data, 0), float32(1))
+	assert.Equal(t, floatAt(data, 12), float32(3))
+	assert.Equal(t, floatAt(data, 24), float32(6))
+	assert.Equal(t, floatAt(data, 28), float32(7))
+	assert.Equal(t, data[32], byte(8))
+	assert.Equal(t, floatAt(data, 60), float32(12))
+}
+
+func TestStd140Arrays(t *testing.T) {
+	var buffer std140Buffer
+
+	buffer.addFloat(1)
+	assert.Equal(t, buffer.addFloatArray([]float32{2, 3, 4}), 16, "Arrays must be aligned to 16 bytes")
+	assert.Equal(t, buffer.addFloat(5), 64, "Array elements must have a 16 byte stride")
+	assert.Equal(t, buffer.addVec2Array([]mgl32.Vec2{{6, 7}, {8, 9}}), 80)
+	assert.Equal(t, buffer.addVec3Array([]mgl32.Vec3{{10, 11, 12}}), 112)
+	assert.Equal(t, buffer.addFloat(13), 128, "A scalar must not fill the padding after an array")
+
+	data := buffer.bytes()
+	assert.Equal(t, len(data), 144)
+	assert.Equal(t, floatAt(data, 16), float32(2))
+	assert.Equal(t, floatAt(data, 32), float32(3))
+	assert.Equal(t, floatAt(data, 48), float32(4))
+	assert.Equal(t, floatAt(data, 96), float32(8))
+	assert.Equal(t, floatAt(data, 120), float32(12))
+}
+
+func TestStd140Matrices(t *testing.T) {
+	var buffer std140Buffer
+
+	buffer.addFloat(1)
+	assert.Equal(t, buffer.addMat3(mgl32.Mat3{1, 2, 3, 4, 5, 6, 7, 8, 9}), 16, "mat3 must be aligned to 16 bytes")
+	assert.Equal(t, buffer.addMat4(mgl32.Ident4()), 64, "mat3 columns must be padded to vec4")
+	assert.Equal(t, buffer.addFloat(2), 128)
+
+	data := buffer.bytes()
+	assert.Equal(t, floatAt(data, 16), float32(1))
+	assert.Equal(t, floatAt(data, 28), float32(0), "mat3 column padding must be zero")
+	assert.Equal(t, floatAt(data, 32), float32(4))
+	assert.Equal(t, floatAt(data, 48), float32(7))
+	assert.Equal(t, floatAt(data, 64), float32(1))
+	assert.Equal(t, floatAt(data, 84), float32(1))
+	assert.Equal(t, len(data), 144)
+}
+
+func TestStd140Struct(t *testing.T) {
+	var buffer std140Buffer
+
+	buffer.addFloat(1)
+	assert.Equal(t, buffer.beginStruct(), 16, "Structs must be aligned to 16 bytes")
+	buffer.addFloat(2)
+	buffer.endStruct()
+	assert.Equal(t, buffer.addFloat(3), 32, "Structs must be padded to 16 bytes")
+
+	buffer.reset()
+	assert.Equal(t, len(buffer.bytes()), 0)
+}
+
+func TestGlobalsBlock(t *testing.T) {
+	var buffer std140Buffer
+	data := packGlobals(&buffer)
+	assert.Equal(t, len(data)%16, 0, "Block size must be a multiple of 16 bytes")
+
+	stage := lintStage{"globals", gl.FRAGMENT_SHADER, parseDeclarations(globalsBlockSource())}
+	assert.Equal(t, len(stage.declarations), len(globalsBlockMembers))
+	assert.Equal(t, len(lintUniforms(stage)), 0, "Generated block must match the packed layout")
+}
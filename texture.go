@@ -2,94 +2,263 @@ package main
 
 import (
 	"fmt"
-	"image"
-	"image/draw"
-	"os"
 
 	"github.com/go-gl/gl/v3.2-core/gl"
+	"github.com/inkyblackness/imgui-go"
 )
 
 type texture struct {
 	name     string
 	id       uint32
 	filePath string
+	sampler  samplerSettings
+	asset    *textureAsset
+	// cube loads the file as a cubemap
+	cube bool
 }
 
-func (t *texture) loadFromFile(filePath string) error {
-	imgFile, err := os.Open(filePath)
-	if err != nil {
-		return fmt.Errorf("texture %q not found on disk: %v", filePath, err)
+// Sampler wrap modes and filters as stored in material files
+const (
+	wrapClamp  string = "clamp"
+	wrapRepeat string = "repeat"
+	wrapMirror string = "mirror"
+
+	filterNearest string = "nearest"
+	filterLinear  string = "linear"
+)
+
+var (
+	wrapModes = []string{wrapClamp, wrapRepeat, wrapMirror}
+	filters   = []string{filterNearest, filterLinear}
+)
+
+// The anisotropic filtering enums of GL_EXT_texture_filter_anisotropic, which the core profile bindings don't have
+const (
+	textureMaxAnisotropy    = 0x84FE
+	maxTextureMaxAnisotropy = 0x84FF
+)
+
+// samplerSettings describes how a texture is sampled
+type samplerSettings struct {
+	WrapS     string `json:"wrapS"`
+	WrapT     string `json:"wrapT"`
+	MinFilter string `json:"minFilter"`
+	MagFilter string `json:"magFilter"`
+	// Mipmaps generates the mipmap chain and filters between the levels when minifying
+	Mipmaps bool `json:"mipmaps"`
+	// Anisotropy is the maximum anisotropic filtering ratio, 1 or less disables it
+	Anisotropy float32 `json:"anisotropy"`
+}
+
+func defaultSamplerSettings() samplerSettings {
+	return samplerSettings{
+		WrapS:      wrapClamp,
+		WrapT:      wrapClamp,
+		MinFilter:  filterLinear,
+		MagFilter:  filterLinear,
+		Anisotropy: 1,
 	}
-	img, _, err := image.Decode(imgFile)
-	if err != nil {
-		return err
+}
+
+func glWrapMode(mode string) int32 {
+	switch mode {
+	case wrapRepeat:
+		return gl.REPEAT
+	case wrapMirror:
+		return gl.MIRRORED_REPEAT
+	default:
+		return gl.CLAMP_TO_EDGE
+	}
+}
+
+func glFilter(filter string) int32 {
+	if filter == filterNearest {
+		return gl.NEAREST
 	}
-	rgba := image.NewRGBA(img.Bounds())
-	if rgba.Stride != rgba.Rect.Size().X*4 {
-		return fmt.Errorf("unsupported stride")
+	return gl.LINEAR
+}
+
+// glMinFilter returns the minification filter, blending between the mipmap levels when there are any
+func glMinFilter(filter string, mipmaps bool) int32 {
+	switch {
+	case !mipmaps:
+		return glFilter(filter)
+	case filter == filterNearest:
+		return gl.NEAREST_MIPMAP_NEAREST
+	default:
+		return gl.LINEAR_MIPMAP_LINEAR
 	}
-	draw.Draw(rgba, rgba.Bounds(), img, image.Point{0, 0}, draw.Src)
+}
 
-	var texID uint32
-	gl.GenTextures(1, &texID)
-	gl.ActiveTexture(gl.TEXTURE0)
+// glExtensions is the set of extensions of the driver. It is queried on first use.
+var glExtensions map[string]bool
 
-	gl.BindTexture(gl.TEXTURE_2D, texID)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
-	gl.TexImage2D(
-		gl.TEXTURE_2D,
-		0,
-		gl.RGBA,
-		int32(rgba.Rect.Size().X),
-		int32(rgba.Rect.Size().Y),
-		0,
-		gl.RGBA,
-		gl.UNSIGNED_BYTE,
-		gl.Ptr(rgba.Pix))
+// hasExtension tells if the driver supports any of the named extensions
+func hasExtension(names ...string) bool {
+	if glExtensions == nil {
+		glExtensions = make(map[string]bool)
+		var count int32
+		gl.GetIntegerv(gl.NUM_EXTENSIONS, &count)
+		for i := uint32(0); i < uint32(count); i++ {
+			glExtensions[gl.GoStr(gl.GetStringi(gl.EXTENSIONS, i))] = true
+		}
+	}
+	for _, name := range names {
+		if glExtensions[name] {
+			return true
+		}
+	}
+	return false
+}
 
-	t.id = texID
-	t.filePath = filePath
+// maxAnisotropy is the largest anisotropic filtering ratio of the driver, 0 without the extension.
+// It is queried on first use.
+var maxAnisotropy = float32(-1)
 
-	return nil
+func supportedAnisotropy() float32 {
+	if maxAnisotropy >= 0 {
+		return maxAnisotropy
+	}
+
+	maxAnisotropy = 0
+	if hasExtension("GL_EXT_texture_filter_anisotropic", "GL_ARB_texture_filter_anisotropic") {
+		gl.GetFloatv(maxTextureMaxAnisotropy, &maxAnisotropy)
+	}
+	return maxAnisotropy
 }
 
-func newTexture(file string) (uint32, error) {
-	imgFile, err := os.Open(file)
-	if err != nil {
-		return 0, fmt.Errorf("texture %q not found on disk: %v", file, err)
+// apply sets the sampler parameters of the texture bound to the target
+func (s samplerSettings) apply(target uint32) {
+	gl.TexParameteri(target, gl.TEXTURE_MIN_FILTER, glMinFilter(s.MinFilter, s.Mipmaps))
+	gl.TexParameteri(target, gl.TEXTURE_MAG_FILTER, glFilter(s.MagFilter))
+	gl.TexParameteri(target, gl.TEXTURE_WRAP_S, glWrapMode(s.WrapS))
+	gl.TexParameteri(target, gl.TEXTURE_WRAP_T, glWrapMode(s.WrapT))
+	if target == gl.TEXTURE_CUBE_MAP {
+		gl.TexParameteri(target, gl.TEXTURE_WRAP_R, glWrapMode(s.WrapT))
+	}
+
+	if supported := supportedAnisotropy(); supported > 0 {
+		anisotropy := s.Anisotropy
+		if anisotropy < 1 {
+			anisotropy = 1
+		}
+		if anisotropy > supported {
+			anisotropy = supported
+		}
+		gl.TexParameterf(target, textureMaxAnisotropy, anisotropy)
+	}
+}
+
+// String describes the settings in one line, e.g. for the asset list
+func (s samplerSettings) String() string {
+	description := fmt.Sprintf("%s/%s %s/%s", s.WrapS, s.WrapT, s.MinFilter, s.MagFilter)
+	if s.Mipmaps {
+		description += " mips"
+	}
+	if s.Anisotropy > 1 {
+		description += fmt.Sprintf(" %gx", s.Anisotropy)
+	}
+	return description
+}
+
+// drawUI draws the wrap, filter, mipmap and anisotropy editors and returns true when a setting changed
+func (s *samplerSettings) drawUI(id string) bool {
+	changed := drawComboString("wrap S##"+id, &s.WrapS, wrapModes)
+	changed = drawComboString("wrap T##"+id, &s.WrapT, wrapModes) || changed
+	changed = drawComboString("min filter##"+id, &s.MinFilter, filters) || changed
+	changed = drawComboString("mag filter##"+id, &s.MagFilter, filters) || changed
+	changed = imgui.Checkbox("mipmaps##"+id, &s.Mipmaps) || changed
+
+	if supported := supportedAnisotropy(); supported > 0 {
+		if s.Anisotropy < 1 {
+			s.Anisotropy = 1
+		}
+		changed = imgui.SliderFloat("anisotropy##"+id, &s.Anisotropy, 1, supported) || changed
+	} else {
+		imgui.Text("anisotropic filtering is not supported")
 	}
-	img, _, err := image.Decode(imgFile)
+	return changed
+}
+
+// load points the texture at the shared asset of the file and sampler settings. A failed load keeps
+// the previously loaded image.
+func (t *texture) load(key textureKey) error {
+	asset, err := textureAssets.acquireKey(key)
 	if err != nil {
-		return 0, err
+		return err
 	}
 
-	rgba := image.NewRGBA(img.Bounds())
-	if rgba.Stride != rgba.Rect.Size().X*4 {
-		return 0, fmt.Errorf("unsupported stride")
+	// Replace the previously loaded image
+	t.delete()
+	t.asset = asset
+	t.id = asset.id
+	t.filePath = key.path
+	t.sampler = key.sampler
+	t.cube = key.cube
+
+	return nil
+}
+
+// delete releases the reference to the shared asset
+func (t *texture) delete() {
+	if t.asset != nil {
+		textureAssets.release(t.asset)
+	}
+	t.asset = nil
+	t.id = 0
+	t.filePath = ""
+}
+
+// The texture swizzle enum of GL_ARB_texture_swizzle, which is core in OpenGL 3.3
+const textureSwizzleRGBA = 0x8E46
+
+// applySwizzle makes one channel images sample as gray and two channel images as gray and alpha,
+// so shaders can read them like RGBA images
+func applySwizzle(target uint32, channels int) {
+	if !hasExtension("GL_ARB_texture_swizzle") {
+		return
 	}
-	draw.Draw(rgba, rgba.Bounds(), img, image.Point{0, 0}, draw.Src)
+	swizzle := []int32{gl.RED, gl.GREEN, gl.BLUE, gl.ALPHA}
+	switch channels {
+	case 1:
+		swizzle = []int32{gl.RED, gl.RED, gl.RED, gl.ONE}
+	case 2:
+		swizzle = []int32{gl.RED, gl.RED, gl.RED, gl.GREEN}
+	}
+	gl.TexParameteriv(target, textureSwizzleRGBA, &swizzle[0])
+}
 
-	var texture uint32
-	gl.GenTextures(1, &texture)
+// uploadTexture fills the given GL texture with the pixels and sets its sampler parameters
+func uploadTexture(texID uint32, img *textureImage, sampler samplerSettings) {
 	gl.ActiveTexture(gl.TEXTURE0)
-	gl.BindTexture(gl.TEXTURE_2D, texture)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
-	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.BindTexture(gl.TEXTURE_2D, texID)
+	sampler.apply(gl.TEXTURE_2D)
+	applySwizzle(gl.TEXTURE_2D, img.channels)
+	texImage2D(gl.TEXTURE_2D, img)
+
+	if sampler.Mipmaps {
+		gl.GenerateMipmap(gl.TEXTURE_2D)
+	}
+}
+
+// texImage2D uploads the pixels to a 2D texture or a cubemap face
+func texImage2D(target uint32, img *textureImage) {
+	format := img.format()
+	pixels := gl.Ptr(img.pixels)
+	if img.floats != nil {
+		pixels = gl.Ptr(img.floats)
+	}
+	// The rows of one and two channel images aren't 4 byte aligned
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
 	gl.TexImage2D(
-		gl.TEXTURE_2D,
+		target,
 		0,
-		gl.RGBA,
-		int32(rgba.Rect.Size().X),
-		int32(rgba.Rect.Size().Y),
+		format.internalFormat,
+		int32(img.width),
+		int32(img.height),
 		0,
-		gl.RGBA,
-		gl.UNSIGNED_BYTE,
-		gl.Ptr(rgba.Pix))
-
-	return texture, nil
+		format.format,
+		format.xtype,
+		pixels)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 4)
 }